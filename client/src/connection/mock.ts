@@ -17,6 +17,10 @@ export class MockConnection implements Connection {
     this.callbacks.push(cb);
   }
 
+  setInterest(): void {
+    // Recordings hold whole snapshots.
+  }
+
   connect(): void {
     if (this.events.length === 0) return;
 
@@ -1,9 +1,11 @@
-import type { WorldState } from "../models/world.js";
+import type { InterestMessage, WorldState } from "../models/world.js";
 
 export type WorldStateCallback = (state: WorldState) => void;
 
 export interface Connection {
   onWorldState(cb: WorldStateCallback): void;
+  /** Narrows what the server streams, such as to the camera's viewport. */
+  setInterest(interest: InterestMessage): void;
   connect(): void;
   disconnect(): void;
 }
@@ -1,12 +1,20 @@
 import type { Connection, WorldStateCallback } from "./types.js";
-import type { WorldState } from "../models/world.js";
+import type { InterestMessage, StreamMessage, WorldState } from "../models/world.js";
 
-const DEFAULT_WS_URL = "ws://localhost:6969/ws";
+const DEFAULT_WS_URL = "ws://localhost:6969/worlds/1/ws";
+
+/** Reconnect delays double from the first up to the last. */
+const RECONNECT_MIN_MS = 500;
+const RECONNECT_MAX_MS = 10_000;
 
 export class WebSocketConnection implements Connection {
   private url: string;
   private ws: WebSocket | null = null;
   private callbacks: WorldStateCallback[] = [];
+  private interest: InterestMessage | null = null;
+  private reconnectMs = RECONNECT_MIN_MS;
+  private reconnectTimer: ReturnType<typeof setTimeout> | null = null;
+  private closed = false;
 
   constructor(url: string = DEFAULT_WS_URL) {
     this.url = url;
@@ -16,23 +24,64 @@ export class WebSocketConnection implements Connection {
     this.callbacks.push(cb);
   }
 
+  setInterest(interest: InterestMessage): void {
+    this.interest = interest;
+    this.sendInterest();
+  }
+
   connect(): void {
-    this.ws = new WebSocket(this.url);
-    this.ws.onmessage = (event) => {
+    this.closed = false;
+    const ws = new WebSocket(this.url);
+    this.ws = ws;
+    ws.onopen = () => {
+      this.reconnectMs = RECONNECT_MIN_MS;
+      // A new stream starts over, it sends every chunk and entity again
+      this.sendInterest();
+    };
+    ws.onmessage = (event) => {
+      let message: WorldState | StreamMessage;
       try {
-        const state = JSON.parse(event.data as string) as WorldState;
-        for (const cb of this.callbacks) cb(state);
+        message = JSON.parse(event.data as string);
       } catch {
-        // ignore parse errors
+        return; // ignore parse errors
+      }
+      if (!("type" in message)) {
+        for (const cb of this.callbacks) cb(message);
+      } else if (message.type === "error") {
+        console.warn("world stream:", message.error);
       }
+      // enter and leave are implied by the entities of the next snapshot,
+      // the scene draws obstacles rather than the chunked grid
     };
-    this.ws.onerror = () => {};
-    this.ws.onclose = () => {
+    // onclose follows every error, reconnecting is left to it
+    ws.onerror = () => {};
+    ws.onclose = (event) => {
+      if (this.ws !== ws) return;
       this.ws = null;
+      if (this.closed) return;
+      console.warn(
+        `world stream closed (${event.code}), reconnecting in ${this.reconnectMs} ms`
+      );
+      this.reconnectTimer = setTimeout(() => {
+        this.reconnectTimer = null;
+        this.connect();
+      }, this.reconnectMs);
+      this.reconnectMs = Math.min(this.reconnectMs * 2, RECONNECT_MAX_MS);
     };
   }
 
+  private sendInterest(): void {
+    if (this.interest && this.ws?.readyState === WebSocket.OPEN) {
+      this.ws.send(JSON.stringify(this.interest));
+    }
+  }
+
   disconnect(): void {
+    this.closed = true;
+    if (this.reconnectTimer !== null) {
+      clearTimeout(this.reconnectTimer);
+      this.reconnectTimer = null;
+    }
     if (this.ws) {
       this.ws.close();
       this.ws = null;
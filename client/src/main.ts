@@ -59,14 +59,33 @@ function updateHud() {
   `;
 }
 
-const useMock = !window.location.search.includes("ws");
+const params = new URLSearchParams(window.location.search);
+const useMock = !params.has("ws");
+const worldId = params.get("world") ?? "1";
 const connection = useMock
   ? new MockConnection()
-  : new WebSocketConnection("ws://localhost:6969/ws");
+  : new WebSocketConnection(`ws://localhost:6969/worlds/${worldId}/ws`);
 
 connection.onWorldState((state) => scene.updateWorldState(state));
 connection.connect();
 
+// Only stream what the camera shows, the server adds a margin around it
+let sentViewport = "";
+setInterval(() => {
+  const viewport = scene.getViewport();
+  if (!viewport) return;
+  const rounded = {
+    x: Math.floor(viewport.x),
+    y: Math.floor(viewport.y),
+    width: Math.ceil(viewport.width),
+    height: Math.ceil(viewport.height),
+  };
+  const key = JSON.stringify(rounded);
+  if (key === sentViewport) return;
+  sentViewport = key;
+  connection.setInterest({ type: "viewport", ...rounded });
+}, 250);
+
 function loop(): void {
   scene.render();
   if (selectedEntityId !== null) updateHud();
@@ -11,6 +11,38 @@ export interface Stats {
   tiredness: number;
 }
 
+export interface StaticObstacle {
+  type: string;
+  position: Vector2D;
+  /** Food or water left, absent for walls. */
+  amount?: number;
+  /** Most food or water held, or animals sheltered by a rest area. */
+  capacity?: number;
+  /** Outline of an obstacle spanning several cells, in cell units. */
+  shape?: Vector2D[];
+  /** Rest areas are round zones of this radius around position. */
+  radius?: number;
+  occupants?: number;
+}
+
+export interface Perception {
+  radius: number;
+  /** Field of view in radians, centered on the entity's direction. */
+  fov: number;
+  /** Fraction of the radius still seen in full darkness. */
+  night_vision: number;
+  /** Radius reduced by darkness on the current tick. */
+  range: number;
+  visible_entities: number[];
+  visible_resources: StaticObstacle[];
+}
+
+export interface Movement {
+  walk_speed: number;
+  run_speed: number;
+  acceleration: number;
+}
+
 export interface Entity {
   id: number;
   type: string;
@@ -19,11 +51,17 @@ export interface Entity {
   direction: Vector2D;
   target_pos?: Vector2D;
   stats: Stats;
-}
-
-export interface StaticObstacle {
-  type: string;
-  position: Vector2D;
+  /** Collision radius in cells. */
+  radius: number;
+  /** Hunger a kill left to eat. */
+  meal?: number;
+  gait: "walk" | "run";
+  /** Current speed in cells per second. */
+  speed: number;
+  movement: Movement;
+  perception: Perception;
+  /** Inside a rest area with room, predators leave it alone. */
+  sheltered: boolean;
 }
 
 export interface StaticObstacles {
@@ -33,16 +71,164 @@ export interface StaticObstacles {
   rest_areas: StaticObstacle[];
 }
 
+/** Per second stat changes, negative values recover. */
+export interface StatRates {
+  hunger: number;
+  thirst: number;
+  tiredness: number;
+}
+
 export interface WorldConfig {
   tps: number;
+  ticks_per_day: number;
+  seed: number;
+  /** Goroutines ticking entities, 0 uses every core. */
+  workers: number;
+  /** Rates per entity type, then per entity state. */
+  metabolism: Record<string, Record<string, StatRates>>;
+  /** Steering weights per entity type. */
+  steering: Record<string, SteeringProfile>;
+  climate: Climate;
+}
+
+export interface SteeringWeights {
+  seek: number;
+  separation: number;
+  alignment: number;
+  cohesion: number;
+  avoidance: number;
+  neighbor_radius: number;
+  separation_radius: number;
+  arrival_radius: number;
+}
+
+export interface SteeringProfile {
+  normal: SteeringWeights;
+  fleeing: SteeringWeights;
+}
+
+export type DayPhase = "dawn" | "day" | "dusk" | "night";
+
+export interface Clock {
+  day: number;
+  /** Fraction of the day, 0 is midnight and 0.5 noon. */
+  time_of_day: number;
+  phase: DayPhase;
+  /** 0 in the dark of night, 1 in daylight. */
+  light: number;
+}
+
+export type Season = "spring" | "summer" | "autumn" | "winter";
+export type Weather = "clear" | "rain" | "drought" | "snow";
+
+export interface WeatherState {
+  season: Season;
+  weather: Weather;
+}
+
+export interface WeatherEffect {
+  food_regrowth: number;
+  water_refill: number;
+  speed: number;
+  metabolism: StatRates;
+}
+
+export interface Climate {
+  days_per_season: number;
+  /** Days between weather rolls. */
+  weather_period: number;
+  food_regrowth: number;
+  water_refill: number;
+  seasons: Record<Season, { odds: Partial<Record<Weather, number>>; food_regrowth: number }>;
+  weather: Record<Weather, WeatherEffect>;
+}
+
+export type EventKind =
+  | "spawned"
+  | "died"
+  | "state_changed"
+  | "arrived"
+  | "season_changed"
+  | "weather_changed"
+  | "obstacle_added"
+  | "obstacle_removed"
+  | "map_painted";
+
+export interface SimEvent {
+  tick: number;
+  kind: EventKind;
+  entity_id: number;
+  /** Absent for world events such as weather changes. */
+  entity_type?: string;
+  /** Killer of a died entity. */
+  other_id?: number;
+  from?: string;
+  to?: string;
+  season?: Season;
+  weather?: Weather;
+  /** Obstacle type of an obstacle_added or obstacle_removed edit. */
+  obstacle?: string;
+  /** A map_painted edit set the cells from position spanning size to this. */
+  walkable?: boolean;
+  size?: Vector2D;
+  position: Vector2D;
 }
 
 export interface WorldState {
+  /** Tick the snapshot was taken at. */
+  tick: number;
+  clock: Clock;
+  weather: WeatherState;
   id: number;
   width: number;
   height: number;
-  navigation_grid: boolean[][];
+  /** Left out by streams, which send MapChunks instead. */
+  navigation_grid?: boolean[][];
+  /** Speed multiplier per cell, 1 is open ground. */
+  terrain?: number[][];
+  /** Changes whenever the map is edited. */
+  map_version: number;
   static_obstacles: StaticObstacles;
   entities: Entity[];
+  /** Everything that happened during the tick. */
+  events: SimEvent[];
   config: WorldConfig;
 }
+
+/** Rectangle of the map in cells, x and y are its top left corner. */
+export interface Viewport {
+  x: number;
+  y: number;
+  width: number;
+  height: number;
+}
+
+export interface ChunkId {
+  x: number;
+  y: number;
+}
+
+/** Square of the map, its first cell is (x * size, y * size). */
+export interface MapChunk extends ChunkId {
+  /** Map version the chunk last changed in. */
+  version: number;
+  width: number;
+  height: number;
+  /** Run lengths row by row, alternating walkable and blocked, walkable first. */
+  walkable: number[];
+  terrain: { speed: number; count: number }[];
+}
+
+/** What a world stream sends besides snapshots, which have no type. */
+export type StreamMessage =
+  | { type: "chunks"; size: number; chunks: MapChunk[] }
+  | { type: "enter"; ids: number[] }
+  | { type: "leave"; ids: number[] }
+  | { type: "error"; error: string };
+
+/** Narrows a world stream, see ClientMessage on the server. */
+export type InterestMessage =
+  | ({ type: "viewport" } & Viewport)
+  | { type: "follow"; id: number; radius?: number }
+  | { type: "all" }
+  | { type: "chunks"; chunks: ChunkId[] };
@@ -1,5 +1,5 @@
 import * as THREE from "three";
-import type { StaticObstacles } from "../models/world.js";
+import type { StaticObstacle, StaticObstacles } from "../models/world.js";
 
 const CELL = 1;
 
@@ -22,31 +22,58 @@ function makeBox(w: number, h: number, d: number, color: number): THREE.Group {
   return group;
 }
 
-/** Walls: gray cuboids. Water: blue low-poly. Food: green/yellow low-poly. */
+/** Extrudes an outline in cell units, cell x spans [x, x + 1). */
+function makeShape(outline: { x: number; y: number }[], h: number, color: number): THREE.Group {
+  const group = new THREE.Group();
+
+  const shape = new THREE.Shape(outline.map((p) => new THREE.Vector2(p.x, p.y)));
+  const geometry = new THREE.ExtrudeGeometry(shape, { depth: h, bevelEnabled: false });
+  // Lay the outline on the ground, the extrusion pointing up
+  geometry.rotateX(Math.PI / 2);
+  geometry.translate(0, h, 0);
+  group.add(new THREE.Mesh(geometry, new THREE.MeshBasicMaterial({ color })));
+
+  const edges = new THREE.EdgesGeometry(geometry);
+  group.add(new THREE.LineSegments(edges, new THREE.LineBasicMaterial({ color: 0x000000, linewidth: 2 })));
+
+  return group;
+}
+
+/** Single cell obstacles are boxes, obstacles with a shape are extruded outlines. */
+function makeObstacle(o: StaticObstacle, size: number, h: number, color: number): THREE.Group {
+  if (o.shape && o.shape.length >= 3) {
+    return makeShape(o.shape, h, color);
+  }
+  const mesh = makeBox(CELL * size, h, CELL * size, color);
+  mesh.position.set(o.position.x + 0.5, h / 2, o.position.y + 0.5);
+  return mesh;
+}
+
+/** Walls: gray cuboids. Water: blue low-poly. Food: green/yellow low-poly. Rest areas: flat green discs. */
 export function createObstacleMeshes(obstacles: StaticObstacles): THREE.Group {
   const group = new THREE.Group();
 
   for (const o of obstacles.walls) {
-    const mesh = makeBox(CELL * 0.9, CELL * 0.5, CELL * 0.9, 0x555555);
-    mesh.position.set(o.position.x + 0.5, 0.25, o.position.y + 0.5);
-    group.add(mesh);
+    group.add(makeObstacle(o, 0.9, CELL * 0.5, 0x555555));
   }
 
   for (const o of obstacles.water_sources) {
-    const mesh = makeBox(CELL * 0.85, CELL * 0.3, CELL * 0.85, 0x2288cc);
-    mesh.position.set(o.position.x + 0.5, 0.15, o.position.y + 0.5);
-    group.add(mesh);
+    group.add(makeObstacle(o, 0.85, CELL * 0.3, 0x2288cc));
   }
 
   for (const o of obstacles.food_sources) {
-    const mesh = makeBox(CELL * 0.7, CELL * 0.4, CELL * 0.7, 0x88aa22);
-    mesh.position.set(o.position.x + 0.5, 0.2, o.position.y + 0.5);
-    group.add(mesh);
+    group.add(makeObstacle(o, 0.7, CELL * 0.4, 0x88aa22));
   }
 
   for (const o of obstacles.rest_areas) {
-    const mesh = makeBox(CELL * 0.8, CELL * 0.2, CELL * 0.8, 0x666666);
-    mesh.position.set(o.position.x + 0.5, 0.1, o.position.y + 0.5);
+    const radius = o.radius ?? CELL * 0.5;
+    const geometry = new THREE.CircleGeometry(radius, 24);
+    geometry.rotateX(-Math.PI / 2);
+    const mesh = new THREE.Mesh(
+      geometry,
+      new THREE.MeshBasicMaterial({ color: 0x66aa66, transparent: true, opacity: 0.4 })
+    );
+    mesh.position.set(o.position.x, 0.02, o.position.y);
     group.add(mesh);
   }
 
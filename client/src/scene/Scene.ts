@@ -1,6 +1,6 @@
 import * as THREE from "three";
 import { OrbitControls } from "three/examples/jsm/controls/OrbitControls.js";
-import type { WorldState } from "../models/world.js";
+import type { Viewport, WorldState } from "../models/world.js";
 import { Interpolator } from "./Interpolator.js";
 import { createTerrain } from "./Terrain.js";
 import { createObstacleMeshes } from "./ObstacleMesh.js";
@@ -19,6 +19,7 @@ export class Scene {
   private interpolator = new Interpolator();
   private terrainGroup: THREE.Group | null = null;
   private obstacleGroup: THREE.Group | null = null;
+  private mapVersion = 0;
   private entityMeshes = new Map<number, THREE.Object3D>();
   private entityGroup = new THREE.Group();
   private selectedEntityId: number | null = null;
@@ -131,9 +132,22 @@ export class Scene {
       this.controls.update();
     }
 
+    // Recorded logs from before map editing have no version
+    const mapVersion = state.map_version ?? 0;
+    if (this.obstacleGroup && this.mapVersion !== mapVersion) {
+      this.scene.remove(this.obstacleGroup);
+      this.obstacleGroup.traverse((child) => {
+        if (child instanceof THREE.Mesh || child instanceof THREE.LineSegments) {
+          child.geometry.dispose();
+          (child.material as THREE.Material).dispose();
+        }
+      });
+      this.obstacleGroup = null;
+    }
     if (!this.obstacleGroup) {
       this.obstacleGroup = createObstacleMeshes(state.static_obstacles);
       this.scene.add(this.obstacleGroup);
+      this.mapVersion = mapVersion;
     }
   }
 
@@ -184,6 +198,25 @@ export class Scene {
     this.renderer.render(this.scene, this.camera);
   }
 
+  /** Part of the ground the camera shows, in world cells. */
+  getViewport(): Viewport | null {
+    const ground = new THREE.Plane(new THREE.Vector3(0, 1, 0), 0);
+    const hit = new THREE.Vector3();
+    let minX = Infinity;
+    let minY = Infinity;
+    let maxX = -Infinity;
+    let maxY = -Infinity;
+    for (const [x, y] of [[-1, -1], [1, -1], [1, 1], [-1, 1]]) {
+      this.raycaster.setFromCamera(new THREE.Vector2(x, y), this.camera);
+      if (!this.raycaster.ray.intersectPlane(ground, hit)) return null;
+      minX = Math.min(minX, hit.x);
+      maxX = Math.max(maxX, hit.x);
+      minY = Math.min(minY, hit.z);
+      maxY = Math.max(maxY, hit.z);
+    }
+    return { x: minX, y: minY, width: maxX - minX, height: maxY - minY };
+  }
+
   getLatestWorld(): WorldState | null {
     return this.interpolator.getLatestWorld();
   }
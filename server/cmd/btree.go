@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game"
+	"github.com/xSaCh/animalia/internal/game/btree"
+)
+
+// btreeCmd dumps behavior trees, e.g. `go run ./cmd btree -format dot -trace 200`
+func btreeCmd(args []string) {
+	fs := flag.NewFlagSet("btree", flag.ExitOnError)
+	format := fs.String("format", "ascii", "output format: ascii, dot or mermaid")
+	entityType := fs.String("type", "", "entity type to dump, all types when empty")
+	traceTicks := fs.Int("trace", 0, "simulate this many ticks and color nodes by the last tick's statuses")
+	fs.Parse(args)
+
+	types := game.BehaviorTreeTypes()
+	if *entityType != "" {
+		types = []common.EntityType{common.EntityType(*entityType)}
+	}
+
+	for _, t := range types {
+		root, ok := game.NewBehaviorTree(t)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no behavior tree for entity type %q\n", t)
+			os.Exit(1)
+		}
+
+		var trace *btree.Trace
+		if *traceTicks > 0 {
+			root, trace = traceBehaviorTree(t, *traceTicks)
+		}
+
+		switch *format {
+		case "ascii":
+			fmt.Printf("# %s\n%s\n", t, btree.ToASCII(root, trace))
+		case "dot":
+			fmt.Print(btree.ToDOT(root, trace))
+		case "mermaid":
+			fmt.Print(btree.ToMermaid(root, trace))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+			os.Exit(1)
+		}
+	}
+}
+
+// traceBehaviorTree runs a small world with one traced entity of the given type
+func traceBehaviorTree(t common.EntityType, ticks int) (btree.Node, *btree.Trace) {
+	world := game.NewWorld(30, TICKS_PER_SECOND)
+	id, ok := world.Spawn(t, randomPosition(world))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cannot spawn entity type %q\n", t)
+		os.Exit(1)
+	}
+	brain := world.Brains.Get(id)
+	brain.EnableTrace()
+	root, trace := brain.Tree, brain.Trace
+
+	for range ticks {
+		world.Tick()
+	}
+	return root, trace
+}
@@ -4,11 +4,13 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/xSaCh/animalia/internal/common"
 	"github.com/xSaCh/animalia/internal/game"
 )
 
@@ -17,7 +19,35 @@ const (
 )
 
 func main() {
-	milliseconds := 1000 / TICKS_PER_SECOND
+	if len(os.Args) > 1 && os.Args[1] == "btree" {
+		btreeCmd(os.Args[2:])
+		return
+	}
+
+	configPath := flag.String("config", "", "JSON config file overriding the default world config")
+	logEvents := flag.Bool("log-events", false, "log simulation events to stderr")
+	flag.Parse()
+
+	cfg := game.DefaultConfig(TICKS_PER_SECOND)
+	if *configPath != "" {
+		var err error
+		cfg, err = game.LoadConfig(*configPath, TICKS_PER_SECOND)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	world := game.NewWorldWithConfig(120, cfg)
+	if *logEvents {
+		events := json.NewEncoder(os.Stderr)
+		world.Events.Subscribe(func(batch []game.Event) {
+			for _, e := range batch {
+				events.Encode(e)
+			}
+		})
+	}
+
+	milliseconds := 1000 / world.Config.TPS
 
 	ticker := time.NewTicker(time.Millisecond * time.Duration(milliseconds))
 	renderTicker := time.NewTicker(time.Millisecond * time.Duration(500))
@@ -36,11 +66,11 @@ func main() {
 		}
 	}()
 
-	world := game.NewWorld(120, TICKS_PER_SECOND)
-	for i := range 10 {
-		pos := world.GetRandomWalkablePosition()
-		goat := game.NewGoat(i+1, pos)
-		world.Entities = append(world.Entities, goat)
+	for range 10 {
+		world.SpawnGoat(randomPosition(world))
+	}
+	for range 2 {
+		world.SpawnWolf(randomPosition(world))
 	}
 	for {
 		select {
@@ -50,7 +80,7 @@ func main() {
 			world.Tick()
 		case <-renderTicker.C:
 			// clearConsole()
-			json.NewEncoder(os.Stdout).Encode(world)
+			json.NewEncoder(os.Stdout).Encode(world.Snapshot())
 			// world.DrawAsciiWorld()
 			// world.PrintEntities()
 
@@ -66,6 +96,16 @@ func main() {
 
 }
 
+// randomPosition returns a spawn point, exiting when the map has none
+func randomPosition(world *game.World) common.Vector2D {
+	pos, err := world.GetRandomWalkablePosition()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return pos
+}
+
 func clearConsole() {
 	fmt.Print("\033[H\033[2J")
 }
@@ -1,7 +1,19 @@
 package main
 
-import "github.com/xSaCh/animalia/internal/server"
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xSaCh/animalia/internal/server"
+)
 
 func main() {
-	server.StartServer(6969)
+	port := flag.Int("port", 6969, "port to serve the HTTP API on")
+	flag.Parse()
+
+	if err := server.StartServer(*port); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -1,5 +1,7 @@
 package common
 
+import "math"
+
 // EntityType represents the type of entity
 type EntityType string
 
@@ -7,6 +9,9 @@ type EntityType string
 type EntityState string
 type ObstacleType string
 
+// Gait is how fast an entity moves and how much moving costs it
+type Gait string
+
 const (
 	EntityTypeGoat EntityType = "goat"
 	EntityTypeWolf EntityType = "wolf"
@@ -18,23 +23,45 @@ const (
 	EntityStateEating   EntityState = "eating"
 	EntityStateResting  EntityState = "resting"
 	EntityStateIdle     EntityState = "idle"
+	EntityStateFleeing  EntityState = "fleeing"
 
 	// ObstacleType
 	ObstacleTypeWall        ObstacleType = "wall"
 	ObstacleTypeWaterSource ObstacleType = "water_source"
 	ObstacleTypeFoodSource  ObstacleType = "food_source"
 	ObstacleTypeRestArea    ObstacleType = "rest_area"
+
+	// Gait
+	GaitWalk Gait = "walk"
+	GaitRun  Gait = "run"
 )
 
 type Stats struct {
-	Hunger    int8 `json:"hunger"`    // 0-100, 0 = full, 100 = starving
-	Thirst    int8 `json:"thirst"`    // 0-100, 0 = hydrated, 100 = dehydrated
-	Tiredness int8 `json:"tiredness"` // 0-100, 0 = fully rested, 100 = exhausted
+	Hunger    float64 `json:"hunger"`    // 0-100, 0 = full, 100 = starving
+	Thirst    float64 `json:"thirst"`    // 0-100, 0 = hydrated, 100 = dehydrated
+	Tiredness float64 `json:"tiredness"` // 0-100, 0 = fully rested, 100 = exhausted
+}
+
+// Clamp keeps every stat within 0-100
+func (s *Stats) Clamp() {
+	s.Hunger = math.Min(100, math.Max(0, s.Hunger))
+	s.Thirst = math.Min(100, math.Max(0, s.Thirst))
+	s.Tiredness = math.Min(100, math.Max(0, s.Tiredness))
 }
 
 type StaticObstacle struct {
 	Type     ObstacleType `json:"type"`
 	Position Vector2D     `json:"position"`
+	Amount   float64      `json:"amount,omitempty"`   // Food or water left
+	Capacity float64      `json:"capacity,omitempty"` // Most food or water it holds, or animals it shelters
+
+	// Outline of an obstacle spanning several cells, Position is then one of
+	// the cells it covers
+	Shape []Vector2D `json:"shape,omitempty"`
+
+	// Rest areas are zones rather than cells
+	Radius    float64 `json:"radius,omitempty"`
+	Occupants int     `json:"occupants,omitempty"`
 }
 
 type StaticObstacles struct {
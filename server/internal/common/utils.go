@@ -36,4 +36,16 @@ func (v Vector2D) Length() float64 {
 
 func (v Vector2D) Distance(other Vector2D) float64 {
 	return v.Subtract(other).Length()
-}
\ No newline at end of file
+}
+func (v Vector2D) Scale(f float64) Vector2D {
+	return Vector2D{v.X * f, v.Y * f}
+}
+
+// Normalize returns the unit vector in v's direction, or zero for a zero vector
+func (v Vector2D) Normalize() Vector2D {
+	l := v.Length()
+	if l == 0 {
+		return Vector2D{}
+	}
+	return Vector2D{v.X / l, v.Y / l}
+}
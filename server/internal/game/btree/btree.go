@@ -8,6 +8,7 @@ Node
 */
 
 type Status int
+type Kind string
 type ActionFn func(*TickContext) Status
 type ConditionFn func(*TickContext) bool
 
@@ -17,20 +18,75 @@ const (
 	Running
 )
 
+const (
+	KindSequence  Kind = "sequence"
+	KindSelector  Kind = "selector"
+	KindAction    Kind = "action"
+	KindCondition Kind = "condition"
+	KindUtility   Kind = "utility"
+	KindPlanner   Kind = "planner"
+)
+
+func (s Status) String() string {
+	switch s {
+	case Success:
+		return "success"
+	case Failure:
+		return "failure"
+	case Running:
+		return "running"
+	}
+	return "unknown"
+}
+
 type TickContext struct {
 	BlackBoard any
 	World      any // Reference to world for accessing game state
 	NodeStates []int
+	Trace      *Trace // Optional, records the status of every node ticked
 }
 
 type Node interface {
 	ID() int
+	Name() string
+	Kind() Kind
+	Children() []Node
 	Tick(*TickContext) Status
 }
 
+// Trace records the last status returned by each node and the last score of
+// each utility option, keyed by node ID
+type Trace struct {
+	Statuses map[int]Status  `json:"statuses"`
+	Scores   map[int]float64 `json:"scores"`
+}
+
+func NewTrace() *Trace {
+	return &Trace{
+		Statuses: make(map[int]Status),
+		Scores:   make(map[int]float64),
+	}
+}
+
+// Reset clears recorded values so the trace only covers the next tick
+func (t *Trace) Reset() {
+	clear(t.Statuses)
+	clear(t.Scores)
+}
+
+// Record stores a node's status in the trace, if any, and returns it. Nodes
+// implemented outside this package should return through it as well.
+func (ctx *TickContext) Record(id int, status Status) Status {
+	if ctx.Trace != nil {
+		ctx.Trace.Statuses[id] = status
+	}
+	return status
+}
+
 // Sequence Node
 type Sequence struct {
 	id       int
+	name     string
 	children []Node
 }
 
@@ -38,6 +94,24 @@ func (s *Sequence) ID() int {
 	return s.id
 }
 
+func (s *Sequence) Name() string {
+	return s.name
+}
+
+func (s *Sequence) Kind() Kind {
+	return KindSequence
+}
+
+func (s *Sequence) Children() []Node {
+	return s.children
+}
+
+// Named sets a human readable name used by exporters
+func (s *Sequence) Named(name string) *Sequence {
+	s.name = name
+	return s
+}
+
 func (s *Sequence) Tick(ctx *TickContext) Status {
 	current := ctx.NodeStates[s.id]
 	for current < len(s.children) {
@@ -47,19 +121,20 @@ func (s *Sequence) Tick(ctx *TickContext) Status {
 			current++
 		case Failure:
 			ctx.NodeStates[s.id] = 0
-			return Failure
+			return ctx.Record(s.id, Failure)
 		case Running:
 			ctx.NodeStates[s.id] = current
-			return Running
+			return ctx.Record(s.id, Running)
 		}
 	}
 	ctx.NodeStates[s.id] = 0
-	return Success
+	return ctx.Record(s.id, Success)
 }
 
 // Selector Node
 type Selector struct {
 	id       int
+	name     string
 	children []Node
 }
 
@@ -67,6 +142,24 @@ func (s *Selector) ID() int {
 	return s.id
 }
 
+func (s *Selector) Name() string {
+	return s.name
+}
+
+func (s *Selector) Kind() Kind {
+	return KindSelector
+}
+
+func (s *Selector) Children() []Node {
+	return s.children
+}
+
+// Named sets a human readable name used by exporters
+func (s *Selector) Named(name string) *Selector {
+	s.name = name
+	return s
+}
+
 func (s *Selector) Tick(ctx *TickContext) Status {
 	current := ctx.NodeStates[s.id]
 	for current < len(s.children) {
@@ -74,47 +167,85 @@ func (s *Selector) Tick(ctx *TickContext) Status {
 		switch status {
 		case Success:
 			ctx.NodeStates[s.id] = 0
-			return Success
+			return ctx.Record(s.id, Success)
 		case Failure:
 			current++
 		case Running:
 			ctx.NodeStates[s.id] = current
-			return Running
+			return ctx.Record(s.id, Running)
 		}
 	}
 	ctx.NodeStates[s.id] = 0
-	return Failure
+	return ctx.Record(s.id, Failure)
 }
 
 // Action Node
 type Action struct {
-	id int
-	fn ActionFn
+	id   int
+	name string
+	fn   ActionFn
 }
 
 func (a *Action) ID() int {
 	return a.id
 }
 
+func (a *Action) Name() string {
+	return a.name
+}
+
+func (a *Action) Kind() Kind {
+	return KindAction
+}
+
+func (a *Action) Children() []Node {
+	return nil
+}
+
+// Named sets a human readable name used by exporters
+func (a *Action) Named(name string) *Action {
+	a.name = name
+	return a
+}
+
 func (a *Action) Tick(ctx *TickContext) Status {
-	return a.fn(ctx)
+	return ctx.Record(a.id, a.fn(ctx))
 }
 
 // Condition Node
 type Condition struct {
-	id int
-	fn ConditionFn
+	id   int
+	name string
+	fn   ConditionFn
 }
 
 func (a *Condition) ID() int {
 	return a.id
 }
 
+func (a *Condition) Name() string {
+	return a.name
+}
+
+func (a *Condition) Kind() Kind {
+	return KindCondition
+}
+
+func (a *Condition) Children() []Node {
+	return nil
+}
+
+// Named sets a human readable name used by exporters
+func (a *Condition) Named(name string) *Condition {
+	a.name = name
+	return a
+}
+
 func (a *Condition) Tick(ctx *TickContext) Status {
 	if a.fn(ctx) {
-		return Success
+		return ctx.Record(a.id, Success)
 	}
-	return Failure
+	return ctx.Record(a.id, Failure)
 }
 
 // IDGenerator provides auto-incrementing IDs for behavior tree nodes
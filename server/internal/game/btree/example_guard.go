@@ -2,16 +2,16 @@ package btree
 
 // How to use bt
 
-/* Guard Behavior Tree
+/* Guard Behavior Tree (generated with ToASCII)
 Root (Selector)
-├─ Sequence: (player visible) -> Engage
+├─ Sequence: engage
 │   ├─ Condition: player_visible?
 │   └─ Selector
-│       ├─ Sequence (attack)
+│       ├─ Sequence: attack
 │       │   ├─ Condition: player_in_range?
 │       │   └─ Action: attack
 │       └─ Action: chase
-├─ Sequence: (low health) -> Heal
+├─ Sequence: heal
 │   ├─ Condition: low_health?
 │   └─ Action: find_cover_and_heal
 └─ Action: patrol
@@ -66,20 +66,20 @@ func NewGuard(id int, info GuardInfo) *Guard {
 
 	bt := NewSelector(idGen.Next(),
 		NewSequence(idGen.Next(),
-			NewCondition(idGen.Next(), isPlayerVisible),
+			NewCondition(idGen.Next(), isPlayerVisible).Named("player_visible"),
 			NewSelector(idGen.Next(),
 				NewSequence(idGen.Next(),
-					NewCondition(idGen.Next(), isPlayerInRange),
-					NewAction(idGen.Next(), attack),
-				),
-				NewAction(idGen.Next(), chase),
+					NewCondition(idGen.Next(), isPlayerInRange).Named("player_in_range"),
+					NewAction(idGen.Next(), attack).Named("attack"),
+				).Named("attack"),
+				NewAction(idGen.Next(), chase).Named("chase"),
 			),
-		),
+		).Named("engage"),
 		NewSequence(idGen.Next(),
-			NewCondition(idGen.Next(), lowHealth),
-			NewAction(idGen.Next(), findCoverAndHeal),
-		),
-		NewAction(idGen.Next(), patrol),
+			NewCondition(idGen.Next(), lowHealth).Named("low_health"),
+			NewAction(idGen.Next(), findCoverAndHeal).Named("find_cover_and_heal"),
+		).Named("heal"),
+		NewAction(idGen.Next(), patrol).Named("patrol"),
 	)
 	return &Guard{
 		id:   id,
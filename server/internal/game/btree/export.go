@@ -0,0 +1,181 @@
+package btree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exporters render a tree for documentation and debugging. When a trace is
+// given, nodes are annotated/colored by the status they returned in it.
+
+var statusColors = map[Status]string{
+	Success: "#a6e3a1",
+	Failure: "#f38ba8",
+	Running: "#f9e2af",
+}
+
+// Walk visits every node depth-first, parents before children
+func Walk(root Node, fn func(n Node, depth int)) {
+	var walk func(n Node, depth int)
+	walk = func(n Node, depth int) {
+		fn(n, depth)
+		for _, c := range n.Children() {
+			walk(c, depth+1)
+		}
+	}
+	walk(root, 0)
+}
+
+// MaxID returns the highest node ID in the tree, useful for sizing NodeStates
+func MaxID(root Node) int {
+	max := 0
+	Walk(root, func(n Node, _ int) {
+		if n.ID() > max {
+			max = n.ID()
+		}
+	})
+	return max
+}
+
+func kindTitle(k Kind) string {
+	if k == "" {
+		return "Node"
+	}
+	return strings.ToUpper(string(k[:1])) + string(k[1:])
+}
+
+// Label returns the display label of a node, e.g. "Condition: is_thirsty?"
+func Label(n Node) string {
+	title := kindTitle(n.Kind())
+	if n.Name() == "" {
+		return title
+	}
+	if n.Kind() == KindCondition {
+		return title + ": " + n.Name() + "?"
+	}
+	return title + ": " + n.Name()
+}
+
+func traceStatus(trace *Trace, n Node) (Status, bool) {
+	if trace == nil {
+		return 0, false
+	}
+	s, ok := trace.Statuses[n.ID()]
+	return s, ok
+}
+
+// annotation describes what the trace recorded for a node, e.g. "running 0.82"
+func annotation(trace *Trace, n Node) string {
+	if trace == nil {
+		return ""
+	}
+	var parts []string
+	if s, ok := trace.Statuses[n.ID()]; ok {
+		parts = append(parts, s.String())
+	}
+	if score, ok := trace.Scores[n.ID()]; ok {
+		parts = append(parts, fmt.Sprintf("%.2f", score))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ToASCII renders the tree in the box drawing style used in doc comments
+func ToASCII(root Node, trace *Trace) string {
+	var b strings.Builder
+
+	rootName := root.Name()
+	if rootName == "" {
+		rootName = "Root"
+	}
+	fmt.Fprintf(&b, "%s (%s)", rootName, kindTitle(root.Kind()))
+	if a := annotation(trace, root); a != "" {
+		fmt.Fprintf(&b, " [%s]", a)
+	}
+	b.WriteString("\n")
+
+	var write func(n Node, prefix string)
+	write = func(n Node, prefix string) {
+		children := n.Children()
+		for i, c := range children {
+			branch, indent := "├─ ", "│   "
+			if i == len(children)-1 {
+				branch, indent = "└─ ", "    "
+			}
+			b.WriteString(prefix + branch + Label(c))
+			if a := annotation(trace, c); a != "" {
+				fmt.Fprintf(&b, " [%s]", a)
+			}
+			b.WriteString("\n")
+			write(c, prefix+indent)
+		}
+	}
+	write(root, "")
+	return b.String()
+}
+
+// ToDOT renders the tree as a Graphviz digraph
+func ToDOT(root Node, trace *Trace) string {
+	var b strings.Builder
+	b.WriteString("digraph BehaviorTree {\n")
+	b.WriteString("  node [fontname=\"Helvetica\" style=filled fillcolor=\"#ffffff\"];\n")
+
+	Walk(root, func(n Node, _ int) {
+		shape := "box"
+		switch n.Kind() {
+		case KindSelector, KindUtility:
+			shape = "diamond"
+		case KindCondition:
+			shape = "ellipse"
+		}
+		label := Label(n)
+		if a := annotation(trace, n); a != "" {
+			label += "\n" + a
+		}
+		attrs := fmt.Sprintf("label=%q shape=%s", label, shape)
+		if s, ok := traceStatus(trace, n); ok {
+			attrs += fmt.Sprintf(" fillcolor=%q", statusColors[s])
+		}
+		fmt.Fprintf(&b, "  n%d [%s];\n", n.ID(), attrs)
+	})
+	Walk(root, func(n Node, _ int) {
+		for _, c := range n.Children() {
+			fmt.Fprintf(&b, "  n%d -> n%d;\n", n.ID(), c.ID())
+		}
+	})
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ToMermaid renders the tree as a Mermaid flowchart
+func ToMermaid(root Node, trace *Trace) string {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	Walk(root, func(n Node, _ int) {
+		label := Label(n)
+		if a := annotation(trace, n); a != "" {
+			label += "<br/>" + a
+		}
+		label = strings.ReplaceAll(label, "\"", "#quot;")
+		switch n.Kind() {
+		case KindSelector, KindUtility:
+			fmt.Fprintf(&b, "  n%d{\"%s\"}\n", n.ID(), label)
+		case KindCondition:
+			fmt.Fprintf(&b, "  n%d([\"%s\"])\n", n.ID(), label)
+		default:
+			fmt.Fprintf(&b, "  n%d[\"%s\"]\n", n.ID(), label)
+		}
+	})
+	Walk(root, func(n Node, _ int) {
+		for _, c := range n.Children() {
+			fmt.Fprintf(&b, "  n%d --> n%d\n", n.ID(), c.ID())
+		}
+	})
+	Walk(root, func(n Node, _ int) {
+		if s, ok := traceStatus(trace, n); ok {
+			fmt.Fprintf(&b, "  style n%d fill:%s\n", n.ID(), statusColors[s])
+		}
+	})
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package btree
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	ids := NewIDGenerator()
+	thirsty := NewCondition(ids.Next(), func(*TickContext) bool { return true }).Named("is_thirsty")
+	drink := NewAction(ids.Next(), func(*TickContext) Status { return Running }).Named("drink")
+	wander := NewAction(ids.Next(), func(*TickContext) Status { return Success }).Named("wander")
+	root := NewSelector(ids.Next(),
+		NewSequence(ids.Next(), thirsty, drink).Named("Thirst"),
+		wander,
+	).Named("Goat")
+
+	want := `Goat (Selector)
+├─ Sequence: Thirst
+│   ├─ Condition: is_thirsty?
+│   └─ Action: drink
+└─ Action: wander
+`
+	if got := ToASCII(root, nil); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	trace := NewTrace()
+	root.Tick(&TickContext{NodeStates: make([]int, MaxID(root)+1), Trace: trace})
+	want = `Goat (Selector) [running]
+├─ Sequence: Thirst [running]
+│   ├─ Condition: is_thirsty? [success]
+│   └─ Action: drink [running]
+└─ Action: wander
+`
+	if got := ToASCII(root, trace); got != want {
+		t.Errorf("traced, got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package btree
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+type ScoreFn func(*TickContext) float64
+
+// Option pairs a child node with the function scoring how useful it is now
+type Option struct {
+	Node  Node
+	Score ScoreFn
+}
+
+// UtilitySelector ticks the child with the highest score. The branch that is
+// already running gets a hysteresis bonus so near-equal needs don't make the
+// entity flip between branches every tick. If the chosen child fails, the
+// next best is tried.
+type UtilitySelector struct {
+	id         int
+	name       string
+	hysteresis float64
+	options    []Option
+}
+
+func NewUtilitySelector(id int, hysteresis float64, options ...Option) *UtilitySelector {
+	return &UtilitySelector{
+		id:         id,
+		hysteresis: hysteresis,
+		options:    options,
+	}
+}
+
+func (u *UtilitySelector) ID() int {
+	return u.id
+}
+
+func (u *UtilitySelector) Name() string {
+	return u.name
+}
+
+func (u *UtilitySelector) Kind() Kind {
+	return KindUtility
+}
+
+func (u *UtilitySelector) Children() []Node {
+	children := make([]Node, len(u.options))
+	for i, o := range u.options {
+		children[i] = o.Node
+	}
+	return children
+}
+
+// Named sets a human readable name used by exporters
+func (u *UtilitySelector) Named(name string) *UtilitySelector {
+	u.name = name
+	return u
+}
+
+func (u *UtilitySelector) Tick(ctx *TickContext) Status {
+	// NodeStates holds the running option index + 1, 0 when none is running
+	running := ctx.NodeStates[u.id] - 1
+
+	scores := make([]float64, len(u.options))
+	for i, o := range u.options {
+		scores[i] = o.Score(ctx)
+		if ctx.Trace != nil {
+			ctx.Trace.Scores[o.Node.ID()] = scores[i]
+		}
+		if i == running {
+			scores[i] += u.hysteresis
+		}
+	}
+
+	order := make([]int, len(u.options))
+	for i := range order {
+		order[i] = i
+	}
+	// Stable so ties keep declaration order, like a regular Selector
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case scores[a] > scores[b]:
+			return -1
+		case scores[a] < scores[b]:
+			return 1
+		}
+		return 0
+	})
+
+	for _, i := range order {
+		if running >= 0 && i != running {
+			// The previous branch is abandoned, so it restarts next time
+			Reset(u.options[running].Node, ctx.NodeStates)
+			running = -1
+		}
+		status := u.options[i].Node.Tick(ctx)
+		switch status {
+		case Running:
+			ctx.NodeStates[u.id] = i + 1
+			return ctx.Record(u.id, Running)
+		case Success:
+			ctx.NodeStates[u.id] = 0
+			return ctx.Record(u.id, Success)
+		case Failure:
+			running = -1
+		}
+	}
+	ctx.NodeStates[u.id] = 0
+	return ctx.Record(u.id, Failure)
+}
+
+// Reset clears the stored state of every node in the subtree
+func Reset(n Node, states []int) {
+	Walk(n, func(n Node, _ int) {
+		if n.ID() < len(states) {
+			states[n.ID()] = 0
+		}
+	})
+}
+
+// CheckStates returns an error naming the first node whose stored state it
+// can't resume from, such as states read from a corrupt save. States are
+// never negative, and composites store a child index of at most their
+// child count.
+func CheckStates(root Node, states []int) error {
+	for id, s := range states {
+		if s < 0 {
+			return fmt.Errorf("node %d has negative state %d", id, s)
+		}
+	}
+	var err error
+	Walk(root, func(n Node, _ int) {
+		switch n.Kind() {
+		case KindSequence, KindSelector, KindUtility:
+		default:
+			return
+		}
+		if s := states[n.ID()]; err == nil && s > len(n.Children()) {
+			err = fmt.Errorf("%s node %d has state %d, it has %d children", n.Kind(), n.ID(), s, len(n.Children()))
+		}
+	})
+	return err
+}
+
+// Response curves map a normalized input in [0, 1] to a score in [0, 1]
+
+type Curve func(x float64) float64
+
+func Clamp01(x float64) float64 {
+	return math.Min(1, math.Max(0, x))
+}
+
+// Normalize maps v from [min, max] to [0, 1]
+func Normalize(v, min, max float64) float64 {
+	if max == min {
+		return 0
+	}
+	return Clamp01((v - min) / (max - min))
+}
+
+func Linear(slope, intercept float64) Curve {
+	return func(x float64) float64 {
+		return Clamp01(slope*x + intercept)
+	}
+}
+
+// Polynomial grows slowly at first and sharply near 1 for exponents above 1
+func Polynomial(exponent float64) Curve {
+	return func(x float64) float64 {
+		return Clamp01(math.Pow(Clamp01(x), exponent))
+	}
+}
+
+// Logistic is an S-curve centered on midpoint, steepness controls the slope
+func Logistic(steepness, midpoint float64) Curve {
+	return func(x float64) float64 {
+		return 1 / (1 + math.Exp(-steepness*(x-midpoint)))
+	}
+}
+
+// Inverse turns a rising curve into a falling one
+func Inverse(c Curve) Curve {
+	return func(x float64) float64 {
+		return 1 - c(x)
+	}
+}
+
+// Constant scores an option the same regardless of state
+func Constant(score float64) ScoreFn {
+	return func(*TickContext) float64 {
+		return score
+	}
+}
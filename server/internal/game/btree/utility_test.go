@@ -0,0 +1,75 @@
+package btree
+
+import (
+	"math"
+	"testing"
+)
+
+// option returns an option ticking an action that counts its ticks
+func option(ids *IDGenerator, status *Status, score *float64, ticks *int) Option {
+	action := NewAction(ids.Next(), func(*TickContext) Status {
+		*ticks++
+		return *status
+	})
+	return Option{Node: action, Score: func(*TickContext) float64 { return *score }}
+}
+
+func TestUtilitySelector(t *testing.T) {
+	ids := NewIDGenerator()
+	eatStatus, drinkStatus := Running, Running
+	eatScore, drinkScore := 0.6, 0.5
+	var eats, drinks int
+	u := NewUtilitySelector(ids.Next(), 0.2,
+		option(ids, &eatStatus, &eatScore, &eats),
+		option(ids, &drinkStatus, &drinkScore, &drinks),
+	)
+	ctx := &TickContext{NodeStates: make([]int, MaxID(u)+1)}
+
+	if s := u.Tick(ctx); s != Running || eats != 1 || drinks != 0 {
+		t.Fatalf("first tick %v, %d eats, %d drinks: want the higher score", s, eats, drinks)
+	}
+	// Within the hysteresis the running branch keeps going
+	drinkScore = 0.7
+	u.Tick(ctx)
+	if eats != 2 || drinks != 0 {
+		t.Errorf("%d eats, %d drinks: switched within the hysteresis", eats, drinks)
+	}
+	drinkScore = 0.9
+	u.Tick(ctx)
+	if eats != 2 || drinks != 1 {
+		t.Errorf("%d eats, %d drinks: didn't switch past the hysteresis", eats, drinks)
+	}
+
+	// A failing branch falls through to the next best
+	drinkStatus = Failure
+	if s := u.Tick(ctx); s != Running || eats != 3 || drinks != 2 {
+		t.Errorf("tick %v, %d eats, %d drinks: want eat after drink failed", s, eats, drinks)
+	}
+	eatStatus = Failure
+	if s := u.Tick(ctx); s != Failure || ctx.NodeStates[u.ID()] != 0 {
+		t.Errorf("tick %v with state %d when every branch fails", s, ctx.NodeStates[u.ID()])
+	}
+}
+
+func TestCurves(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"normalize", Normalize(75, 50, 100), 0.5},
+		{"normalize below", Normalize(20, 50, 100), 0},
+		{"normalize empty range", Normalize(5, 5, 5), 0},
+		{"linear", Linear(2, -0.5)(0.5), 0.5},
+		{"linear clamped", Linear(2, 0)(0.8), 1},
+		{"polynomial", Polynomial(2)(0.5), 0.25},
+		{"logistic midpoint", Logistic(10, 0.3)(0.3), 0.5},
+		{"inverse", Inverse(Polynomial(2))(0.5), 0.75},
+		{"constant", Constant(0.4)(nil), 0.4},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %g, want %g", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package game
+
+import (
+	"math"
+	"slices"
+	"sync/atomic"
+)
+
+// ChunkSize is the side of a map chunk in cells. Streams send the map chunk
+// by chunk instead of whole grids, which run into megabytes on large maps.
+const ChunkSize = 32
+
+// ChunkID names a chunk by column and row, its first cell is
+// (X*ChunkSize, Y*ChunkSize)
+type ChunkID struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// MapChunk is the navigation grid and terrain of one chunk, run-length
+// encoded row by row
+type MapChunk struct {
+	ChunkID
+	Version  uint         `json:"version"` // Map version the chunk last changed in
+	Width    int          `json:"width"`   // Less than ChunkSize along the map's right and bottom edges
+	Height   int          `json:"height"`
+	Walkable []int        `json:"walkable"` // Run lengths, alternating walkable and blocked, starting with walkable
+	Terrain  []TerrainRun `json:"terrain"`
+}
+
+// TerrainRun is Count cells in a row with the same speed modifier
+type TerrainRun struct {
+	Speed float64 `json:"speed"`
+	Count int     `json:"count"`
+}
+
+// mapChunks tracks the chunks of a MapSnapshot
+type mapChunks struct {
+	cols, rows int
+	versions   []uint
+	encoded    []atomic.Pointer[MapChunk] // Filled on first use, snapshots are read concurrently
+}
+
+// newMapChunks versions the chunks of m. Chunks whose cells are the same as
+// in prev keep their version, the others take m's.
+func newMapChunks(m, prev *MapSnapshot) mapChunks {
+	rows := len(m.NavigationGrid)
+	cols := 0
+	if rows > 0 {
+		cols = len(m.NavigationGrid[0])
+	}
+	c := mapChunks{
+		cols: int(math.Ceil(float64(cols) / ChunkSize)),
+		rows: int(math.Ceil(float64(rows) / ChunkSize)),
+	}
+	c.versions = make([]uint, c.cols*c.rows)
+	c.encoded = make([]atomic.Pointer[MapChunk], c.cols*c.rows)
+	sameSize := prev != nil && prev.chunks.cols == c.cols && prev.chunks.rows == c.rows &&
+		len(prev.NavigationGrid) == rows && (rows == 0 || len(prev.NavigationGrid[0]) == cols)
+	for cy := range c.rows {
+		for cx := range c.cols {
+			i := cy*c.cols + cx
+			c.versions[i] = m.Version
+			if sameSize && sameChunk(m, prev, cx, cy) {
+				c.versions[i] = prev.chunks.versions[i]
+				c.encoded[i].Store(prev.chunks.encoded[i].Load())
+			}
+		}
+	}
+	return c
+}
+
+// sameChunk reports whether a chunk's cells are equal in both maps
+func sameChunk(a, b *MapSnapshot, cx, cy int) bool {
+	x0, y0, x1, y1 := chunkBounds(a, cx, cy)
+	for y := y0; y < y1; y++ {
+		if !slices.Equal(a.NavigationGrid[y][x0:x1], b.NavigationGrid[y][x0:x1]) ||
+			!slices.Equal(a.Terrain[y][x0:x1], b.Terrain[y][x0:x1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkBounds returns the cells of a chunk, max exclusive
+func chunkBounds(m *MapSnapshot, cx, cy int) (x0, y0, x1, y1 int) {
+	x0, y0 = cx*ChunkSize, cy*ChunkSize
+	return x0, y0, min(x0+ChunkSize, len(m.NavigationGrid[0])), min(y0+ChunkSize, len(m.NavigationGrid))
+}
+
+// ChunkVersion returns the map version a chunk last changed in, false when
+// the map has no such chunk
+func (m *MapSnapshot) ChunkVersion(id ChunkID) (uint, bool) {
+	if id.X < 0 || id.Y < 0 || id.X >= m.chunks.cols || id.Y >= m.chunks.rows {
+		return 0, false
+	}
+	return m.chunks.versions[id.Y*m.chunks.cols+id.X], true
+}
+
+// Chunks returns every chunk of the map
+func (m *MapSnapshot) Chunks() []ChunkID {
+	return m.chunkRange(0, 0, m.chunks.cols-1, m.chunks.rows-1)
+}
+
+// ChunksIn returns the chunks overlapping an area
+func (m *MapSnapshot) ChunksIn(a Area) []ChunkID {
+	if m.chunks.cols == 0 || a.Max.X <= a.Min.X || a.Max.Y <= a.Min.Y {
+		return nil
+	}
+	chunkOf := func(v float64, n int) int {
+		return min(max(int(math.Floor(v/ChunkSize)), 0), n-1)
+	}
+	return m.chunkRange(
+		chunkOf(a.Min.X, m.chunks.cols), chunkOf(a.Min.Y, m.chunks.rows),
+		chunkOf(a.Max.X, m.chunks.cols), chunkOf(a.Max.Y, m.chunks.rows),
+	)
+}
+
+func (m *MapSnapshot) chunkRange(cx0, cy0, cx1, cy1 int) []ChunkID {
+	var ids []ChunkID
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			ids = append(ids, ChunkID{cx, cy})
+		}
+	}
+	return ids
+}
+
+// Chunk returns a chunk encoded for streaming, false when the map has no
+// such chunk. Encoded chunks are shared and must not be modified.
+func (m *MapSnapshot) Chunk(id ChunkID) (*MapChunk, bool) {
+	version, ok := m.ChunkVersion(id)
+	if !ok {
+		return nil, false
+	}
+	slot := &m.chunks.encoded[id.Y*m.chunks.cols+id.X]
+	if c := slot.Load(); c != nil {
+		return c, true
+	}
+
+	x0, y0, x1, y1 := chunkBounds(m, id.X, id.Y)
+	c := &MapChunk{ChunkID: id, Version: version, Width: x1 - x0, Height: y1 - y0}
+	walkable, run := true, 0
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if m.NavigationGrid[y][x] != walkable {
+				c.Walkable = append(c.Walkable, run)
+				walkable, run = !walkable, 0
+			}
+			run++
+
+			speed := m.Terrain[y][x]
+			if n := len(c.Terrain); n > 0 && c.Terrain[n-1].Speed == speed {
+				c.Terrain[n-1].Count++
+			} else {
+				c.Terrain = append(c.Terrain, TerrainRun{Speed: speed, Count: 1})
+			}
+		}
+	}
+	c.Walkable = append(c.Walkable, run)
+	slot.CompareAndSwap(nil, c) // Another reader may have won, both are equal
+	return slot.Load(), true
+}
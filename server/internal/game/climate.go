@@ -0,0 +1,185 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Season is the part of the year, each lasts Climate.DaysPerSeason days
+type Season string
+
+const (
+	SeasonSpring Season = "spring"
+	SeasonSummer Season = "summer"
+	SeasonAutumn Season = "autumn"
+	SeasonWinter Season = "winter"
+)
+
+var seasons = []Season{SeasonSpring, SeasonSummer, SeasonAutumn, SeasonWinter}
+
+// Weather is the current weather, rolled from the season's odds
+type Weather string
+
+const (
+	WeatherClear   Weather = "clear"
+	WeatherRain    Weather = "rain"
+	WeatherDrought Weather = "drought"
+	WeatherSnow    Weather = "snow"
+)
+
+// weathers fixes the order weather odds are rolled in, maps don't have one
+var weathers = []Weather{WeatherClear, WeatherRain, WeatherDrought, WeatherSnow}
+
+// Resource sizes, one unit of food or water recovers one stat point
+const (
+	foodCapacity  = 100
+	waterCapacity = 300
+)
+
+// Climate drives seasons, weather and how fast resources come back
+type Climate struct {
+	DaysPerSeason int     `json:"days_per_season"`
+	WeatherPeriod float64 `json:"weather_period"` // Days between weather rolls
+	FoodRegrowth  float64 `json:"food_regrowth"`  // Units per second per food source
+	WaterRefill   float64 `json:"water_refill"`   // Units per second per water source
+
+	Seasons map[Season]SeasonProfile  `json:"seasons"`
+	Weather map[Weather]WeatherEffect `json:"weather"`
+}
+
+// SeasonProfile is how a season behaves
+type SeasonProfile struct {
+	Odds         map[Weather]float64 `json:"odds"`          // Relative chance of each weather
+	FoodRegrowth float64             `json:"food_regrowth"` // Multiplier
+}
+
+// WeatherEffect is how a weather changes the world, multipliers are 1 for
+// no effect
+type WeatherEffect struct {
+	FoodRegrowth float64   `json:"food_regrowth"`
+	WaterRefill  float64   `json:"water_refill"` // Negative dries water sources up
+	Speed        float64   `json:"speed"`
+	Metabolism   StatRates `json:"metabolism"` // Multiplies stat costs, recovery is unaffected
+}
+
+// DefaultClimate returns a temperate climate
+func DefaultClimate() Climate {
+	return Climate{
+		DaysPerSeason: 2,
+		WeatherPeriod: 0.25,
+		FoodRegrowth:  3,
+		WaterRefill:   8,
+		Seasons: map[Season]SeasonProfile{
+			SeasonSpring: {FoodRegrowth: 1.5, Odds: map[Weather]float64{WeatherClear: 5, WeatherRain: 4, WeatherDrought: 0, WeatherSnow: 0}},
+			SeasonSummer: {FoodRegrowth: 1, Odds: map[Weather]float64{WeatherClear: 6, WeatherRain: 1, WeatherDrought: 3, WeatherSnow: 0}},
+			SeasonAutumn: {FoodRegrowth: 0.7, Odds: map[Weather]float64{WeatherClear: 4, WeatherRain: 5, WeatherDrought: 0, WeatherSnow: 1}},
+			SeasonWinter: {FoodRegrowth: 0.2, Odds: map[Weather]float64{WeatherClear: 4, WeatherRain: 1, WeatherDrought: 0, WeatherSnow: 5}},
+		},
+		Weather: map[Weather]WeatherEffect{
+			WeatherClear:   {FoodRegrowth: 1, WaterRefill: 1, Speed: 1, Metabolism: StatRates{1, 1, 1}},
+			WeatherRain:    {FoodRegrowth: 1.3, WaterRefill: 3, Speed: 0.9, Metabolism: StatRates{1, 1, 1.1}},
+			WeatherDrought: {FoodRegrowth: 0.3, WaterRefill: -0.5, Speed: 1, Metabolism: StatRates{1, 1.5, 1}},
+			WeatherSnow:    {FoodRegrowth: 0, WaterRefill: 0.5, Speed: 0.6, Metabolism: StatRates{1.3, 1, 1.3}},
+		},
+	}
+}
+
+// WeatherState is the current season and weather
+type WeatherState struct {
+	Season  Season  `json:"season"`
+	Weather Weather `json:"weather"`
+}
+
+// Weather returns the current season and weather
+func (w *World) Weather() WeatherState {
+	return w.weather
+}
+
+// weatherEffect returns the effects of the current weather
+func (w *World) weatherEffect() WeatherEffect {
+	if e, ok := w.Config.Climate.Weather[w.weather.Weather]; ok {
+		return e
+	}
+	return WeatherEffect{FoodRegrowth: 1, WaterRefill: 1, Speed: 1, Metabolism: StatRates{1, 1, 1}}
+}
+
+// season returns the season of the current day
+func (w *World) season() Season {
+	days := max(w.Config.Climate.DaysPerSeason, 1)
+	return seasons[(w.Clock().Day/days)%len(seasons)]
+}
+
+// rollWeather draws a weather from the season's odds with the world's
+// seeded random source
+func (w *World) rollWeather(s Season) Weather {
+	odds := w.Config.Climate.Seasons[s].Odds
+	total := 0.0
+	for _, wt := range weathers {
+		total += odds[wt]
+	}
+	if total <= 0 {
+		return WeatherClear
+	}
+	r := w.rng.Float64() * total
+	for _, wt := range weathers {
+		if r < odds[wt] {
+			return wt
+		}
+		r -= odds[wt]
+	}
+	return WeatherClear
+}
+
+// climateSystem advances seasons and weather, then regrows food and refills
+// or dries up water
+func (w *World) climateSystem() {
+	climate := w.Config.Climate
+	prev := w.weather
+	w.weather.Season = w.season()
+	period := max(1, uint(climate.WeatherPeriod*float64(w.Config.TicksPerDay)))
+	if (w.tick-1)%period == 0 {
+		w.weather.Weather = w.rollWeather(w.weather.Season)
+	}
+	if w.weather.Season != prev.Season {
+		w.emit(Event{Kind: EventSeasonChanged, Season: w.weather.Season, Weather: w.weather.Weather})
+	}
+	if w.weather.Weather != prev.Weather {
+		w.emit(Event{Kind: EventWeatherChanged, Season: w.weather.Season, Weather: w.weather.Weather})
+	}
+
+	dt := w.DeltaTime()
+	effect := w.weatherEffect()
+	food := climate.FoodRegrowth * climate.Seasons[w.weather.Season].FoodRegrowth * effect.FoodRegrowth * dt
+	for i := range w.StaticObstacles.FoodSources {
+		refill(&w.StaticObstacles.FoodSources[i], food, foodCapacity)
+	}
+	water := climate.WaterRefill * effect.WaterRefill * dt
+	for i := range w.StaticObstacles.WaterSources {
+		refill(&w.StaticObstacles.WaterSources[i], water, waterCapacity)
+	}
+}
+
+// refill adds amount per cell sized source, a lake refills as fast as all
+// the single cell sources it could hold
+func refill(o *common.StaticObstacle, amount, cellCapacity float64) {
+	amount *= o.Capacity / cellCapacity
+	o.Amount = math.Min(o.Capacity, math.Max(0, o.Amount+amount))
+}
+
+// consume takes up to amount from the nearest non empty source whose edge is
+// within reach of pos and returns how much it got
+func consume(sources []common.StaticObstacle, pos common.Vector2D, amount float64) float64 {
+	best, bestDist := -1, math.Inf(1)
+	for i, o := range sources {
+		if _, d := closestPoint(o, pos); o.Amount > 0 && d <= resourceReach && d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	taken := math.Min(amount, sources[best].Amount)
+	sources[best].Amount -= taken
+	return taken
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+func TestRollWeather(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.Config.Climate.Seasons[SeasonSummer] = SeasonProfile{Odds: map[Weather]float64{WeatherRain: 1, WeatherDrought: 3}}
+	w.Config.Climate.Seasons[SeasonWinter] = SeasonProfile{Odds: map[Weather]float64{WeatherSnow: 2}}
+	w.Config.Climate.Seasons[SeasonAutumn] = SeasonProfile{}
+
+	counts := map[Weather]int{}
+	for range 4000 {
+		counts[w.rollWeather(SeasonSummer)]++
+	}
+	if counts[WeatherClear]+counts[WeatherSnow] > 0 || math.Abs(float64(counts[WeatherDrought])/4000-0.75) > 0.03 {
+		t.Errorf("rolled %v in summer, want drought 3 times as often as rain", counts)
+	}
+	if got := w.rollWeather(SeasonWinter); got != WeatherSnow {
+		t.Errorf("rolled %s with only snow possible", got)
+	}
+	if got := w.rollWeather(SeasonAutumn); got != WeatherClear {
+		t.Errorf("rolled %s without odds, want clear", got)
+	}
+}
+
+func TestClimateSystem(t *testing.T) {
+	cfg := DefaultConfig(20)
+	cfg.TicksPerDay = 100
+	cfg.Climate.DaysPerSeason = 1
+	cfg.Climate.WeatherPeriod = 1
+	for _, s := range seasons {
+		cfg.Climate.Seasons[s] = SeasonProfile{FoodRegrowth: 1, Odds: map[Weather]float64{WeatherDrought: 1}}
+	}
+	w := NewWorldWithConfig(20, cfg)
+	var seasonChanges int
+	w.Events.Subscribe(func(batch []Event) {
+		for _, e := range batch {
+			if e.Kind == EventSeasonChanged {
+				seasonChanges++
+			}
+		}
+	})
+	water := &w.StaticObstacles.WaterSources
+	*water = append((*water)[:0], common.StaticObstacle{Type: common.ObstacleTypeWaterSource, Amount: 100, Capacity: waterCapacity})
+	food := &w.StaticObstacles.FoodSources
+	*food = append((*food)[:0], common.StaticObstacle{Type: common.ObstacleTypeFoodSource, Amount: 10, Capacity: foodCapacity})
+
+	for range 250 {
+		w.Tick()
+	}
+	// Spring, summer then autumn, each a day of drought
+	if got := w.Weather(); got.Season != SeasonAutumn || got.Weather != WeatherDrought {
+		t.Errorf("weather %+v after 2.5 days", got)
+	}
+	if seasonChanges != 3 {
+		t.Errorf("%d season changes, want 3", seasonChanges)
+	}
+	dt := w.DeltaTime()
+	wantWater := 100 - 250*cfg.Climate.WaterRefill*0.5*dt
+	wantFood := 10 + 250*cfg.Climate.FoodRegrowth*0.3*dt
+	if got := (*water)[0].Amount; math.Abs(got-wantWater) > 1e-6 {
+		t.Errorf("water holds %g, want %g after drying up", got, wantWater)
+	}
+	if got := (*food)[0].Amount; math.Abs(got-wantFood) > 1e-6 {
+		t.Errorf("food holds %g, want %g after slow regrowth", got, wantFood)
+	}
+}
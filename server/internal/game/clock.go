@@ -0,0 +1,56 @@
+package game
+
+import "math"
+
+// DayPhase is the part of the day the world clock is in
+type DayPhase string
+
+const (
+	PhaseDawn  DayPhase = "dawn"
+	PhaseDay   DayPhase = "day"
+	PhaseDusk  DayPhase = "dusk"
+	PhaseNight DayPhase = "night"
+)
+
+// Phase boundaries as fractions of a day, 0 is midnight
+const (
+	dawnStart = 0.2
+	dayStart  = 0.3
+	duskStart = 0.7
+	nightFrom = 0.8
+
+	// startTimeOfDay is the time of day at tick 0, worlds start in the morning
+	startTimeOfDay = dayStart
+)
+
+// Clock is the in-game time derived from the world tick
+type Clock struct {
+	Day       int      `json:"day"`         // Days passed since the world started
+	TimeOfDay float64  `json:"time_of_day"` // Fraction of the day in [0, 1), 0.5 is noon
+	Phase     DayPhase `json:"phase"`
+	Light     float64  `json:"light"` // 0 in the dark of night, 1 in daylight
+}
+
+// Clock returns the in-game time of the current tick
+func (w *World) Clock() Clock {
+	days := startTimeOfDay + float64(w.tick)/float64(w.Config.TicksPerDay)
+	day, timeOfDay := math.Modf(days)
+	c := Clock{Day: int(day), TimeOfDay: timeOfDay}
+
+	switch {
+	case timeOfDay < dawnStart || timeOfDay >= nightFrom:
+		c.Phase, c.Light = PhaseNight, 0
+	case timeOfDay < dayStart:
+		c.Phase, c.Light = PhaseDawn, (timeOfDay-dawnStart)/(dayStart-dawnStart)
+	case timeOfDay < duskStart:
+		c.Phase, c.Light = PhaseDay, 1
+	default:
+		c.Phase, c.Light = PhaseDusk, (nightFrom-timeOfDay)/(nightFrom-duskStart)
+	}
+	return c
+}
+
+// IsNight reports whether it is currently night
+func (w *World) IsNight() bool {
+	return w.Clock().Phase == PhaseNight
+}
@@ -0,0 +1,41 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClockPhases(t *testing.T) {
+	cfg := DefaultConfig(20)
+	cfg.TicksPerDay = 100
+	w := NewWorldWithConfig(20, cfg)
+
+	// Worlds start at the beginning of the day, 0.3 of the way through it
+	tests := []struct {
+		tick  uint
+		day   int
+		phase DayPhase
+		light float64
+	}{
+		{0, 0, PhaseDay, 1},
+		{39, 0, PhaseDay, 1},
+		{40, 0, PhaseDusk, 1},
+		{45, 0, PhaseDusk, 0.5},
+		{50, 0, PhaseNight, 0},
+		{70, 1, PhaseNight, 0},
+		{95, 1, PhaseDawn, 0.5},
+		{100, 1, PhaseDay, 1},
+		{242, 2, PhaseDusk, 0.8},
+	}
+	for _, tt := range tests {
+		w.tick = tt.tick
+		c := w.Clock()
+		if c.Day != tt.day || c.Phase != tt.phase || math.Abs(c.Light-tt.light) > 1e-9 {
+			t.Errorf("tick %d: day %d %s with light %g, want day %d %s with light %g",
+				tt.tick, c.Day, c.Phase, c.Light, tt.day, tt.phase, tt.light)
+		}
+		if w.IsNight() != (tt.phase == PhaseNight) {
+			t.Errorf("tick %d: IsNight %v", tt.tick, w.IsNight())
+		}
+	}
+}
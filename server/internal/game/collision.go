@@ -0,0 +1,122 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+const (
+	// collisionPasses bounds how many times overlaps are re-resolved per tick,
+	// since pushing one pair apart can create a new overlap with a third entity
+	collisionPasses = 3
+
+	// interactionRange is how close an entity must be to a resource to use it,
+	// resources sit on blocked cells so entities stop next to them
+	interactionRange = 1.5
+
+	// resourceReach is the furthest an entity can use a resource from. An
+	// entity that reached its approach point counts even when others crowd it
+	// off the exact spot.
+	resourceReach = interactionRange + arriveDistance
+)
+
+// cellOf returns the grid cell containing pos, cell x spans [x, x+1)
+func cellOf(pos common.Vector2D) (int, int) {
+	return int(math.Floor(pos.X)), int(math.Floor(pos.Y))
+}
+
+// collider is an entity's transform and radius during collision resolution
+type collider struct {
+	id     int
+	t      *Transform
+	radius float64
+}
+
+// resolveCollisions runs after every entity has ticked. It pushes apart
+// entities whose radii overlap and then moves any entity that ended up on a
+// blocked cell back toward where it started the tick. Entities are processed
+// in ID order so the result is deterministic.
+func (w *World) resolveCollisions() {
+	entities := make([]collider, 0, w.Transforms.Len())
+	maxRadius := 0.0
+	for id, t := range w.Transforms.All() {
+		c := collider{id: id, t: t}
+		if body := w.Bodies.Get(id); body != nil {
+			c.radius = body.Radius
+		}
+		maxRadius = max(maxRadius, c.radius)
+		entities = append(entities, c)
+	}
+	position := func(i int) common.Vector2D { return entities[i].t.Position }
+
+	for range collisionPasses {
+		// Only entities within two radii can overlap, so each is checked
+		// against the buckets around it. A push can carry an entity out of
+		// its bucket, the next pass buckets it again.
+		grid := newSpatialIndex(w.Width, w.Height, max(1, 2*maxRadius), len(entities), position)
+		moved := false
+		for i, a := range entities {
+			for _, j := range grid.query(AreaAround(a.t.Position, a.radius+maxRadius), position) {
+				if j > i && separate(a, entities[j]) {
+					moved = true
+				}
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	for _, e := range entities {
+		start := e.t.Position
+		if v := w.View(e.id); v != nil {
+			start = v.Position
+		}
+		w.keepOnWalkable(e.t, start)
+	}
+}
+
+// separate pushes two overlapping entities apart by half the overlap each
+func separate(a, b collider) bool {
+	minDist := a.radius + b.radius
+	offset := b.t.Position.Subtract(a.t.Position)
+	dist := offset.Length()
+	if minDist <= 0 || dist >= minDist {
+		return false
+	}
+
+	var normal common.Vector2D
+	if dist == 0 {
+		// Exactly stacked, split along X with the lower ID going left
+		normal = common.Vector2D{X: 1}
+	} else {
+		normal = offset.Scale(1 / dist)
+	}
+	push := normal.Scale((minDist - dist) / 2)
+	a.t.Position = a.t.Position.Subtract(push)
+	b.t.Position = b.t.Position.Add(push)
+	return true
+}
+
+// keepOnWalkable moves an entity off blocked cells. It first tries to slide
+// along one axis from its start position, then falls back to the start.
+func (w *World) keepOnWalkable(e *Transform, start common.Vector2D) {
+	if w.IsWalkable(cellOf(e.Position)) {
+		return
+	}
+	candidates := []common.Vector2D{
+		{X: e.Position.X, Y: start.Y},
+		{X: start.X, Y: e.Position.Y},
+		start,
+	}
+	for _, c := range candidates {
+		if w.IsWalkable(cellOf(c)) {
+			e.Position = c
+			return
+		}
+	}
+	// Started the tick on a blocked cell (spawned or the map changed under it),
+	// leave it where it is rather than teleporting
+	e.Position = start
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// overlapping returns how many pairs of entities overlap by more than slack
+func overlapping(w *World, slack float64) int {
+	n := 0
+	for i, a := range w.Views() {
+		for _, b := range w.Views()[i+1:] {
+			if w.Transforms.Get(a.ID).Position.Distance(w.Transforms.Get(b.ID).Position) < a.Radius+b.Radius-slack {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestResolveCollisions(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	// Across a bucket border, and stacked exactly
+	a := w.SpawnGoat(common.Vector2D{X: 4.9, Y: 5.5})
+	b := w.SpawnGoat(common.Vector2D{X: 5.1, Y: 5.5})
+	c := w.SpawnGoat(common.Vector2D{X: 12.5, Y: 12.5})
+	d := w.SpawnGoat(common.Vector2D{X: 12.5, Y: 12.5})
+	w.captureView()
+	w.resolveCollisions()
+
+	for _, pair := range [][2]int{{a, b}, {c, d}} {
+		pa, pb := w.Transforms.Get(pair[0]).Position, w.Transforms.Get(pair[1]).Position
+		if dist := pa.Distance(pb); math.Abs(dist-0.7) > 1e-9 {
+			t.Errorf("entities %d and %d %.3f apart, want 0.7", pair[0], pair[1], dist)
+		}
+		if pa.X >= pb.X {
+			t.Errorf("entity %d pushed past %d", pair[0], pair[1])
+		}
+	}
+}
+
+// Entities pushed toward a wall stay off its cells
+func TestResolveCollisionsKeepsOffWalls(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.PaintWalkable(10, 0, 10, 19, false)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 60 {
+		w.SpawnGoat(common.Vector2D{X: 8.5 + 1.4*rng.Float64(), Y: 8 + 4*rng.Float64()})
+	}
+	w.captureView()
+	before := overlapping(w, 0.01)
+	w.resolveCollisions()
+
+	for id, tr := range w.Transforms.All() {
+		if !w.IsWalkable(cellOf(tr.Position)) {
+			t.Errorf("entity %d pushed onto blocked cell %v", id, tr.Position)
+		}
+	}
+	if after := overlapping(w, 0.01); after >= before {
+		t.Errorf("%d overlapping pairs, %d before resolving", after, before)
+	}
+}
+
+func BenchmarkResolveCollisions(b *testing.B) {
+	w := newEmptyWorld(b, 200)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 5000 {
+		w.SpawnGoat(common.Vector2D{X: 200 * rng.Float64(), Y: 200 * rng.Float64()})
+	}
+	w.captureView()
+	for b.Loop() {
+		w.resolveCollisions()
+	}
+}
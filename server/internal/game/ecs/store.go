@@ -0,0 +1,95 @@
+// Package ecs holds the entity component system storage used by the game.
+// Entities are plain integer IDs and each kind of component lives in its own
+// Store, so systems iterate contiguous slices of the one component they need.
+package ecs
+
+import (
+	"iter"
+	"slices"
+)
+
+// Store keeps one component per entity in a dense slice ordered by entity
+// ID. Iteration is cache friendly and always in ID order, lookups by ID go
+// through an index. Pointers returned by Get, At and All stay valid until the
+// next Add or Remove.
+type Store[T any] struct {
+	ids   []int
+	data  []T
+	index map[int]int
+}
+
+// Add sets the component of an entity, replacing any existing one
+func (s *Store[T]) Add(id int, v T) {
+	if s.index == nil {
+		s.index = make(map[int]int)
+	}
+	if i, ok := s.index[id]; ok {
+		s.data[i] = v
+		return
+	}
+	// IDs are handed out in increasing order, so this is almost always an append
+	i, _ := slices.BinarySearch(s.ids, id)
+	s.ids = slices.Insert(s.ids, i, id)
+	s.data = slices.Insert(s.data, i, v)
+	s.reindex(i)
+}
+
+// Remove deletes the component of an entity, false if it had none
+func (s *Store[T]) Remove(id int) bool {
+	i, ok := s.index[id]
+	if !ok {
+		return false
+	}
+	delete(s.index, id)
+	s.ids = slices.Delete(s.ids, i, i+1)
+	s.data = slices.Delete(s.data, i, i+1) // Zeroes the freed slot
+	s.reindex(i)
+	return true
+}
+
+func (s *Store[T]) reindex(from int) {
+	for i := from; i < len(s.ids); i++ {
+		s.index[s.ids[i]] = i
+	}
+}
+
+// Get returns the component of an entity, or nil if it has none
+func (s *Store[T]) Get(id int) *T {
+	i, ok := s.index[id]
+	if !ok {
+		return nil
+	}
+	return &s.data[i]
+}
+
+// Has reports whether the entity has this component
+func (s *Store[T]) Has(id int) bool {
+	_, ok := s.index[id]
+	return ok
+}
+
+// Len returns the number of entities with this component
+func (s *Store[T]) Len() int {
+	return len(s.ids)
+}
+
+// ID returns the entity at dense index i
+func (s *Store[T]) ID(i int) int {
+	return s.ids[i]
+}
+
+// At returns the component at dense index i
+func (s *Store[T]) At(i int) *T {
+	return &s.data[i]
+}
+
+// All iterates entities and their components in ID order
+func (s *Store[T]) All() iter.Seq2[int, *T] {
+	return func(yield func(int, *T) bool) {
+		for i, id := range s.ids {
+			if !yield(id, &s.data[i]) {
+				return
+			}
+		}
+	}
+}
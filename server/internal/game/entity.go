@@ -1,54 +1,283 @@
 package game
 
 import (
+	"math"
+	"math/rand/v2"
+	"slices"
+
 	"github.com/xSaCh/animalia/internal/common"
 	"github.com/xSaCh/animalia/internal/game/btree"
 )
 
-type Entity interface {
-	Tick(world *World)
-	GetBaseEntity() *BaseEntity
+// Components. An entity is just an ID, what it is and does depends on
+// which of these it has in the world's stores.
+
+// Body is what an entity is, every entity has one
+type Body struct {
+	Type   common.EntityType  `json:"type"`
+	State  common.EntityState `json:"state"`
+	Radius float64            `json:"radius"`         // Collision radius
+	Meal   float64            `json:"meal,omitempty"` // Hunger a kill left to eat, eaten before any food source
+}
+
+// Transform places an entity in the world, every entity has one
+type Transform struct {
+	Position  common.Vector2D `json:"position"`
+	Direction common.Vector2D `json:"direction"` // Unit heading, zero until the entity first moves
+}
+
+// Motion lets an entity travel toward a target
+type Motion struct {
+	TargetPos *common.Vector2D `json:"target_pos,omitempty"`
+	Gait      common.Gait      `json:"gait"`
+	Speed     float64          `json:"speed"` // Current speed in units per second
+	Movement  Movement         `json:"movement"`
+
+	path      []common.Vector2D // Remaining waypoints toward TargetPos
+	pathGoal  cell              // Cell the path leads to
+	pathValid bool
+}
+
+// Brain runs a behavior tree for an entity every tick
+type Brain struct {
+	Tree  btree.Node
+	Trace *btree.Trace // Statuses of the last tick, nil unless tracing
+	Mind  Mind         // Blackboard the tree runs against
+
+	states  []int      // Track state for each node in behavior tree
+	rng     *rand.Rand // Reseeded every tick, see seedRand
+	pcg     *rand.PCG
+	intents []func(*World) // Queued by Intend, applied in the write phase
+	events  []Event        // Recorded by Emit, collected in the write phase
+}
+
+// NewBrain returns a brain running tree against mind
+func NewBrain(tree btree.Node, mind Mind) Brain {
+	return Brain{
+		Tree:   tree,
+		Mind:   mind,
+		states: make([]int, btree.MaxID(tree)+1),
+	}
+}
+
+// EnableTrace starts recording node statuses on every tick
+func (b *Brain) EnableTrace() {
+	if b.Trace == nil {
+		b.Trace = btree.NewTrace()
+	}
+}
+
+// Mind is a species' blackboard. Species embed Agent, which satisfies it,
+// and add whatever memory their tree needs.
+type Mind interface {
+	agent() *Agent
 }
 
-// BaseEntity represents a movable entity in the world
-type BaseEntity struct {
-	ID        int                `json:"id"`
-	Type      common.EntityType  `json:"type"`
-	Position  common.Vector2D    `json:"position"`
-	State     common.EntityState `json:"state"`
-	Direction common.Vector2D    `json:"direction"` // Direction vector/velocity
-	TargetPos *common.Vector2D   `json:"target_pos,omitempty"`
-	Stats     common.Stats       `json:"stats"`
+// Agent is a handle to the components of an entity with a brain. The brain
+// system rebinds it before every tick, so behavior code reads and writes
+// components as plain fields. Stores only change between ticks, the
+// pointers are not valid across ticks.
+type Agent struct {
+	ID int
 
-	prevState         common.EntityState
-	lastStateChangeAt uint // How entity will have access to tick ?
+	*Body
+	*Transform
+	*Motion
+	Stats      *common.Stats
+	Perception *Perception
 
-	bt      btree.Node
-	btState []int // Track state for each node in behavior tree
+	brain *Brain
+}
 
+func (a *Agent) agent() *Agent {
+	return a
 }
 
-func (e *BaseEntity) GetBaseEntity() *BaseEntity {
-	return e
+// agent binds a handle to the entity's components, false if it lacks any
+func (w *World) agent(id int) (Agent, bool) {
+	a := Agent{
+		ID:         id,
+		Body:       w.Bodies.Get(id),
+		Transform:  w.Transforms.Get(id),
+		Motion:     w.Motions.Get(id),
+		Stats:      w.Stats.Get(id),
+		Perception: w.Perceptions.Get(id),
+		brain:      w.Brains.Get(id),
+	}
+	ok := a.Body != nil && a.Transform != nil && a.Motion != nil &&
+		a.Stats != nil && a.Perception != nil && a.brain != nil
+	return a, ok
 }
 
-func (e *BaseEntity) MoveTowardTarget() {
+// think ticks the brain's tree with its mind bound to the entity
+func (b *Brain) think(a Agent, world *World) {
+	*b.Mind.agent() = a
+	ctx := &btree.TickContext{
+		BlackBoard: b.Mind,
+		World:      world,
+		NodeStates: b.states,
+		Trace:      b.Trace,
+	}
+	if b.Trace != nil {
+		b.Trace.Reset()
+	}
+	// Walking is the default, actions that need to hurry switch to running
+	a.Gait = common.GaitWalk
+	b.Tree.Tick(ctx)
+}
+
+// Movement holds an entity's speed limits in units per second
+type Movement struct {
+	WalkSpeed    float64 `json:"walk_speed"`
+	RunSpeed     float64 `json:"run_speed"`
+	Acceleration float64 `json:"acceleration"` // Units per second squared
+}
+
+// MaxSpeed returns the top speed of the current gait
+func (m *Motion) MaxSpeed() float64 {
+	if m.Gait == common.GaitRun {
+		return m.Movement.RunSpeed
+	}
+	return m.Movement.WalkSpeed
+}
+
+// arriveDistance is how close counts as having reached a target. It is
+// larger than two radii so an entity already standing on a spot doesn't keep
+// others walking toward it forever.
+const arriveDistance = 1.0
+
+// AtTarget reports whether the entity has no target or is close enough to it
+func (e *Agent) AtTarget() bool {
+	return e.TargetPos == nil || e.Position.Distance(*e.TargetPos) <= arriveDistance
+}
+
+func (e *Agent) MoveTowardTarget(world *World) {
 
 	// Calculate direction vector from current position to target
 	dir := e.TargetPos.Subtract(e.Position)
 	distance := dir.Length()
+	if distance == 0 {
+		e.path, e.pathValid = nil, false
+		return
+	}
 
-	// If close enough to target, snap to target position
-	if distance <= 0.5 {
-		e.Position = *e.TargetPos
+	// Blend seeking the next waypoint with the species' herd and avoidance behaviors
+	waypoint := e.nextWaypoint(world)
+	heading := e.steer(world, waypoint, waypoint.SameAs(*e.TargetPos))
+	if heading.IsZero() {
 		return
 	}
+	e.Direction = heading.Normalize()
 
-	// Normalize direction vector and apply speed
-	e.Direction.X = dir.X / distance
-	e.Direction.Y = dir.Y / distance
+	// Accelerate toward the gait's speed, slowed by arrival and rough terrain
+	dt := world.DeltaTime()
+	x, y := cellOf(e.Position)
+	desired := e.MaxSpeed() * heading.Length() * world.SpeedModifier(x, y) * world.weatherEffect().Speed
+	if e.Speed < desired {
+		e.Speed = math.Min(desired, e.Speed+e.Movement.Acceleration*dt)
+	} else {
+		e.Speed = math.Max(desired, e.Speed-e.Movement.Acceleration*dt)
+	}
 
-	// Move towards target with speed of 1
-	e.Position.X += e.Direction.X
-	e.Position.Y += e.Direction.Y
-}
\ No newline at end of file
+	// Snap to the target instead of overshooting it
+	step := e.Speed * dt
+	if distance <= step {
+		e.Position = *e.TargetPos
+		e.path, e.pathValid = nil, false
+	} else {
+		e.Position = e.Position.Add(e.Direction.Scale(step))
+	}
+	if distance > arriveDistance && e.AtTarget() {
+		e.Emit(EventArrived, 0)
+	}
+}
+
+// nextWaypoint returns the point to head for on the way to TargetPos. The
+// path is recomputed when the target moves to another cell or the entity was
+// pushed away from it, and falls back to a straight line when no path exists.
+func (e *Agent) nextWaypoint(world *World) common.Vector2D {
+	goal := cell{}
+	goal.X, goal.Y = cellOf(*e.TargetPos)
+
+	// Trips to a resource follow its flow field, see flowStep
+	current := cell{}
+	current.X, current.Y = cellOf(e.Position)
+	if next, ok := world.flowStep(current, goal); ok {
+		e.path, e.pathValid = nil, false
+		if next == goal {
+			return *e.TargetPos
+		}
+		return common.Vector2D{X: float64(next.X) + 0.5, Y: float64(next.Y) + 0.5}
+	}
+
+	strayed := len(e.path) > 0 && e.Position.Distance(e.path[0]) > 2
+	if !e.pathValid || e.pathGoal != goal || strayed {
+		e.path, e.pathValid = world.findPath(e.Position, *e.TargetPos)
+		e.pathGoal = goal
+	}
+
+	for len(e.path) > 0 && e.Position.Distance(e.path[0]) <= 0.5 {
+		e.path = e.path[1:]
+	}
+	// The last waypoint is the target's cell, aim for the exact target instead
+	if len(e.path) <= 1 {
+		return *e.TargetPos
+	}
+	return e.path[0]
+}
+
+// randomWalkablePosition draws a walkable position from the entity's own
+// random source, safe to call while entities tick in parallel
+func (e *Agent) randomWalkablePosition(world *World) common.Vector2D {
+	pos, err := world.randomPositionIn(e.brain.rng, world.Region(e.Position))
+	if err != nil {
+		return e.Position
+	}
+	return pos
+}
+
+// behaviorTrees builds a fresh behavior tree for each species that has one
+var behaviorTrees = map[common.EntityType]func() btree.Node{
+	common.EntityTypeGoat: createGoatBehaviorTree,
+	common.EntityTypeWolf: createWolfBehaviorTree,
+}
+
+// minds build an empty blackboard for each species with a behavior tree
+var minds = map[common.EntityType]func() Mind{
+	common.EntityTypeGoat: func() Mind { return &Goat{} },
+	common.EntityTypeWolf: func() Mind { return &Wolf{} },
+}
+
+// NewBehaviorTree returns a new, untouched behavior tree for the entity type
+func NewBehaviorTree(t common.EntityType) (btree.Node, bool) {
+	create, ok := behaviorTrees[t]
+	if !ok {
+		return nil, false
+	}
+	return create(), true
+}
+
+// BehaviorTreeTypes lists entity types that have a behavior tree, sorted
+func BehaviorTreeTypes() []common.EntityType {
+	types := make([]common.EntityType, 0, len(behaviorTrees))
+	for t := range behaviorTrees {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// spawners add an entity of each species to a world and return its ID
+var spawners = map[common.EntityType]func(*World, common.Vector2D) int{
+	common.EntityTypeGoat: (*World).SpawnGoat,
+	common.EntityTypeWolf: (*World).SpawnWolf,
+}
+
+// Spawn adds an entity of the given type, false if the type is unknown
+func (w *World) Spawn(t common.EntityType, position common.Vector2D) (int, bool) {
+	spawn, ok := spawners[t]
+	if !ok {
+		return 0, false
+	}
+	return spawn(w, position), true
+}
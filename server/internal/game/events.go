@@ -0,0 +1,128 @@
+package game
+
+import (
+	"sync"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// EventKind names something that happened in the simulation
+type EventKind string
+
+const (
+	EventSpawned         EventKind = "spawned"          // Entity was added to the world
+	EventDied            EventKind = "died"             // Entity was removed, OtherID is the killer if any
+	EventStateChanged    EventKind = "state_changed"    // Entity went from From to To
+	EventArrived         EventKind = "arrived"          // Entity came within reach of its target
+	EventSeasonChanged   EventKind = "season_changed"   // World event with EntityID 0
+	EventWeatherChanged  EventKind = "weather_changed"  // Season and Weather hold the new state
+	EventObstacleAdded   EventKind = "obstacle_added"   // Map edit, Obstacle was placed at Position
+	EventObstacleRemoved EventKind = "obstacle_removed" // Map edit, Obstacle was cleared from Position
+	EventMapPainted      EventKind = "map_painted"      // Map edit, cells from Position spanning Size were set to Walkable
+)
+
+// Event is one thing that happened during a tick
+type Event struct {
+	Tick       uint                `json:"tick"`
+	Kind       EventKind           `json:"kind"`
+	EntityID   int                 `json:"entity_id"`
+	EntityType common.EntityType   `json:"entity_type,omitempty"`
+	OtherID    int                 `json:"other_id,omitempty"`
+	From       common.EntityState  `json:"from,omitempty"`
+	To         common.EntityState  `json:"to,omitempty"`
+	Season     Season              `json:"season,omitempty"`
+	Weather    Weather             `json:"weather,omitempty"`
+	Obstacle   common.ObstacleType `json:"obstacle,omitempty"`
+	Walkable   *bool               `json:"walkable,omitempty"`
+	Size       *common.Vector2D    `json:"size,omitempty"`
+	Position   common.Vector2D     `json:"position"`
+}
+
+// EventBus hands every tick's events to its subscribers as one batch, in the
+// order they happened. Subscribers run on the tick goroutine and must not
+// block or keep the batch beyond the call without copying it.
+type EventBus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]func([]Event)
+}
+
+// Subscribe registers fn to receive each tick's batch, call the returned
+// function to unsubscribe. It is safe to call from any goroutine.
+func (b *EventBus) Subscribe(fn func(batch []Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[int]func([]Event))
+	}
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// publish delivers a batch to every subscriber, in subscription order
+func (b *EventBus) publish(batch []Event) {
+	b.mu.Lock()
+	subs := make([]func([]Event), 0, len(b.subs))
+	for id := 1; id <= b.nextID; id++ {
+		if fn, ok := b.subs[id]; ok {
+			subs = append(subs, fn)
+		}
+	}
+	b.mu.Unlock()
+	for _, fn := range subs {
+		fn(batch)
+	}
+}
+
+// emit records an event from a system running in the write phase
+func (w *World) emit(e Event) {
+	e.Tick = w.tick
+	w.events = append(w.events, e)
+}
+
+// Emit records an event about the agent. It is safe while entities tick in
+// parallel, the events join the tick's batch in entity ID order.
+func (a *Agent) Emit(kind EventKind, otherID int) {
+	a.brain.events = append(a.brain.events, Event{
+		Kind:       kind,
+		EntityID:   a.ID,
+		EntityType: a.Type,
+		OtherID:    otherID,
+		Position:   a.Position,
+	})
+}
+
+// stateSystem emits an event for every entity whose state changed this tick
+func (w *World) stateSystem() {
+	for id, body := range w.Bodies.All() {
+		v := w.View(id)
+		if v == nil || v.State == body.State {
+			continue
+		}
+		e := Event{
+			Kind:       EventStateChanged,
+			EntityID:   id,
+			EntityType: body.Type,
+			From:       v.State,
+			To:         body.State,
+		}
+		if t := w.Transforms.Get(id); t != nil {
+			e.Position = t.Position
+		}
+		w.emit(e)
+	}
+}
+
+// eventSystem hands the tick's batch to subscribers and starts a new one.
+// The batch is never reused since the published snapshot holds it.
+func (w *World) eventSystem() {
+	batch := w.events
+	w.events = nil
+	w.Events.publish(batch)
+}
@@ -0,0 +1,53 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+func TestEventBus(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.Tick() // Publishes clearing the map
+	var batches [][]Event
+	var order []string
+	w.Events.Subscribe(func(batch []Event) {
+		batches = append(batches, batch)
+		order = append(order, "first")
+	})
+	unsubscribe := w.Events.Subscribe(func([]Event) { order = append(order, "second") })
+
+	goat := w.SpawnGoat(common.Vector2D{X: 5.5, Y: 5.5})
+	w.Tick()
+	if len(batches) != 1 || len(batches[0]) == 0 || batches[0][0].Kind != EventSpawned || batches[0][0].EntityID != goat {
+		t.Fatalf("first batch %+v, want the goat spawning first", batches)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("subscribers called in order %v", order)
+	}
+
+	unsubscribe()
+	for range 40 {
+		w.Tick()
+	}
+	w.RemoveEntity(goat)
+	removedAt := w.tick
+	w.Tick()
+	if len(order) != 43 {
+		t.Errorf("%d deliveries, want 43 with the second subscriber gone after the first tick", len(order))
+	}
+	last := batches[len(batches)-1]
+	if len(last) != 1 || last[0].Kind != EventDied || last[0].EntityID != goat || last[0].Tick != removedAt {
+		t.Errorf("last batch %+v, want the goat dying after tick %d", last, removedAt)
+	}
+	for _, batch := range batches {
+		for i, e := range batch {
+			if i > 0 && e.Tick < batch[i-1].Tick {
+				t.Errorf("event %+v after one of tick %d", e, batch[i-1].Tick)
+			}
+			if e.Kind == EventStateChanged && (e.From == e.To || e.EntityID != goat) {
+				t.Errorf("state change %+v", e)
+			}
+		}
+	}
+}
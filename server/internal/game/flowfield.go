@@ -0,0 +1,252 @@
+package game
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Flow fields replace per entity searches for trips to water and food. Each
+// source gets a field holding, for every cell within flowRadius of it, the
+// cost of the cheapest path from that cell to a walkable cell next to the
+// source, its shore. Entities walk downhill one neighbor at a time, so a
+// herd heading for the same pond shares one search, and the field doubles
+// as an exact distance for behavior scores. Farther away entities path
+// toward the source until they reach its field. An edit rebuilds the fields
+// covering a cell it changed, and fields are only read during ticks.
+
+// flowRadius is how far around its source a field reaches, in cells. It
+// keeps fields small on large maps, where one over the whole map would take
+// megabytes per source.
+const flowRadius = 64
+
+type flowField struct {
+	source cell      // Cell of the source's position, like regionMap.shores
+	bounds area      // Cells covered, the source's grown by flowRadius
+	shore  []cell    // Walkable cells next to the source, where costs are 0
+	costs  []float64 // Laid out with bounds, infinite where unreachable
+}
+
+// buildFlowFields computes a field for every water and food source
+func (w *World) buildFlowFields() {
+	w.flows = nil
+	w.flowDirty = nil
+	w.updateFlowFields()
+}
+
+// updateFlowFields rebuilds the fields covering cells changed since the
+// last update and those of new sources, and drops those of removed sources
+func (w *World) updateFlowFields() {
+	var sources []common.StaticObstacle
+	sources = append(sources, w.StaticObstacles.WaterSources...)
+	sources = append(sources, w.StaticObstacles.FoodSources...)
+	fields := make([]*flowField, len(sources))
+	var stale []int
+	for i, o := range sources {
+		key := cell{}
+		key.X, key.Y = cellOf(o.Position)
+		if f := w.flows[key]; f != nil && (w.flowDirty == nil || !f.bounds.overlaps(*w.flowDirty)) {
+			fields[i] = f
+		} else {
+			stale = append(stale, i)
+		}
+	}
+	w.parallel(len(stale), func(i int) {
+		fields[stale[i]] = w.newFlowField(sources[stale[i]])
+	})
+	w.flowDirty = nil
+
+	w.flows = make(map[cell]*flowField, len(fields))
+	w.shoreFields = map[cell][]*flowField{}
+	for _, f := range fields {
+		w.flows[f.source] = f
+		for _, c := range f.shore {
+			w.shoreFields[c] = append(w.shoreFields[c], f)
+		}
+	}
+}
+
+// markFlowDirty schedules the fields covering cell (x, y) for rebuilding
+func (w *World) markFlowDirty(x, y int) {
+	if w.flowDirty == nil {
+		w.flowDirty = &area{x, y, x, y}
+		return
+	}
+	d := w.flowDirty
+	d.minX, d.minY = min(d.minX, x), min(d.minY, y)
+	d.maxX, d.maxY = max(d.maxX, x), max(d.maxY, y)
+}
+
+// newFlowField runs Dijkstra backwards from the source's shore, so costs are
+// those of walking toward it even where slow terrain makes them differ by
+// direction
+func (w *World) newFlowField(o common.StaticObstacle) *flowField {
+	cells := obstacleCells(o)
+	bounds := area{cells[0].X, cells[0].Y, cells[0].X, cells[0].Y}
+	for _, c := range cells {
+		bounds.minX, bounds.minY = min(bounds.minX, c.X), min(bounds.minY, c.Y)
+		bounds.maxX, bounds.maxY = max(bounds.maxX, c.X), max(bounds.maxY, c.Y)
+	}
+	bounds = bounds.grow(flowRadius).clip(w.bounds())
+
+	f := &flowField{bounds: bounds, costs: make([]float64, bounds.width()*bounds.height())}
+	f.source.X, f.source.Y = cellOf(o.Position)
+	for i := range f.costs {
+		f.costs[i] = math.Inf(1)
+	}
+
+	open := &pathQueue{}
+	for _, c := range cells {
+		for _, d := range neighborOffsets {
+			shore := cell{c.X + d.X, c.Y + d.Y}
+			if !w.IsWalkable(shore.X, shore.Y) || f.costs[bounds.index(shore)] == 0 {
+				continue
+			}
+			f.costs[bounds.index(shore)] = 0
+			f.shore = append(f.shore, shore)
+			heap.Push(open, &pathNode{cell: shore})
+		}
+	}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*pathNode)
+		if current.cost > f.costs[bounds.index(current.cell)] {
+			continue
+		}
+		for _, d := range neighborOffsets {
+			prev := cell{current.cell.X + d.X, current.cell.Y + d.Y}
+			back := cell{-d.X, -d.Y}
+			if !bounds.contains(prev) || !w.IsWalkable(prev.X, prev.Y) || !w.canStep(prev, back) {
+				continue
+			}
+			pi := bounds.index(prev)
+			cost := current.cost + w.stepCost(back, current.cell)
+			if f.costs[pi] <= cost {
+				continue
+			}
+			f.costs[pi] = cost
+			heap.Push(open, &pathNode{cell: prev, cost: cost, score: cost})
+		}
+	}
+	return f
+}
+
+// cost returns the field's cost at cell c, infinite outside the field
+func (f *flowField) cost(c cell) float64 {
+	if !f.bounds.contains(c) {
+		return math.Inf(1)
+	}
+	return f.costs[f.bounds.index(c)]
+}
+
+// downhill returns the neighbor of c one step along the cheapest path to
+// the shore, false when c is on the shore or can't reach it
+func (w *World) downhill(f *flowField, c cell) (cell, bool) {
+	if cost := f.cost(c); cost == 0 || math.IsInf(cost, 1) {
+		return c, false
+	}
+	best, bestCost := c, math.Inf(1)
+	for _, d := range neighborOffsets {
+		next := cell{c.X + d.X, c.Y + d.Y}
+		if !w.canStep(c, d) {
+			continue
+		}
+		// The step counts too, a diagonal can beat a slightly lower neighbor
+		if cost := w.stepCost(d, next) + f.cost(next); cost < bestCost {
+			best, bestCost = next, cost
+		}
+	}
+	return best, best != c
+}
+
+// nearestField returns the field of the source with the cheapest path from
+// pos among those with anything left, nil when none can be reached
+func (w *World) nearestField(sources []common.StaticObstacle, pos common.Vector2D) (*flowField, float64) {
+	c := cell{}
+	c.X, c.Y = cellOf(pos)
+	var nearest *flowField
+	best := math.Inf(1)
+	for _, o := range sources {
+		if o.Amount <= 0 {
+			continue
+		}
+		key := cell{}
+		key.X, key.Y = cellOf(o.Position)
+		if f := w.flows[key]; f != nil {
+			if cost := f.cost(c); cost < best {
+				nearest, best = f, cost
+			}
+		}
+	}
+	return nearest, best
+}
+
+// NearestWaterDistance returns the cost of walking from pos to the nearest
+// water source that has not dried up, infinite when none can be reached
+func (w *World) NearestWaterDistance(pos common.Vector2D) float64 {
+	return w.resourceDistance(w.StaticObstacles.WaterSources, pos)
+}
+
+// NearestFoodDistance returns the cost of walking from pos to the nearest
+// food source with food left, infinite when none can be reached
+func (w *World) NearestFoodDistance(pos common.Vector2D) float64 {
+	return w.resourceDistance(w.StaticObstacles.FoodSources, pos)
+}
+
+// resourceDistance reads the fields. Entities pushed onto a blocked cell are
+// on no field and get the straight line distance instead.
+func (w *World) resourceDistance(sources []common.StaticObstacle, pos common.Vector2D) float64 {
+	if _, cost := w.nearestField(sources, pos); !math.IsInf(cost, 1) {
+		return cost
+	}
+	_, dist := w.nearestResource(sources, pos)
+	return dist
+}
+
+// NearestWaterApproach returns the center of the shore cell at the end of
+// the cheapest walk from pos to water and the walk's cost
+func (w *World) NearestWaterApproach(pos common.Vector2D) (common.Vector2D, float64) {
+	return w.approach(w.StaticObstacles.WaterSources, pos)
+}
+
+// NearestFoodApproach returns the center of the shore cell at the end of
+// the cheapest walk from pos to food and the walk's cost
+func (w *World) NearestFoodApproach(pos common.Vector2D) (common.Vector2D, float64) {
+	return w.approach(w.StaticObstacles.FoodSources, pos)
+}
+
+// approach follows the nearest field down to the shore, or heads for the
+// closest source from a blocked cell like resourceDistance
+func (w *World) approach(sources []common.StaticObstacle, pos common.Vector2D) (common.Vector2D, float64) {
+	f, cost := w.nearestField(sources, pos)
+	if f == nil {
+		target, dist := w.nearestResource(sources, pos)
+		if math.IsInf(dist, 1) {
+			return target, dist
+		}
+		return w.ApproachPoint(target, pos), dist
+	}
+	c := cell{}
+	c.X, c.Y = cellOf(pos)
+	for next, ok := w.downhill(f, c); ok; next, ok = w.downhill(f, c) {
+		c = next
+	}
+	return c.center(), cost
+}
+
+// flowStep returns the next cell on the way to goal when goal is on the
+// shore of a source, so walking to a resource needs no search. The field
+// may lead to another cell of the same shore, false once there.
+func (w *World) flowStep(c, goal cell) (cell, bool) {
+	var best *flowField
+	bestCost := math.Inf(1)
+	for _, f := range w.shoreFields[goal] {
+		if cost := f.cost(c); cost < bestCost {
+			best, bestCost = f, cost
+		}
+	}
+	if best == nil {
+		return c, false
+	}
+	return w.downhill(best, c)
+}
@@ -0,0 +1,168 @@
+package goap
+
+import (
+	"container/heap"
+	"slices"
+	"strings"
+
+	"github.com/xSaCh/animalia/internal/game/btree"
+)
+
+// Goal-oriented action planning: the planner searches for the cheapest chain
+// of actions whose effects turn the current state into one that satisfies a
+// goal. Facts missing from a State are treated as false.
+
+// State is a set of named boolean facts, e.g. {"has_prey": true}
+type State map[string]bool
+
+// Satisfies reports whether every fact in want has the same value in s
+func (s State) Satisfies(want State) bool {
+	for fact, value := range want {
+		if s[fact] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns a copy of s with effects applied
+func (s State) Apply(effects State) State {
+	next := make(State, len(s)+len(effects))
+	for fact, value := range s {
+		next[fact] = value
+	}
+	for fact, value := range effects {
+		next[fact] = value
+	}
+	return next
+}
+
+// unsatisfied counts facts of want that s does not match, used as heuristic
+func (s State) unsatisfied(want State) int {
+	n := 0
+	for fact, value := range want {
+		if s[fact] != value {
+			n++
+		}
+	}
+	return n
+}
+
+// key is a canonical string of the true facts, used to detect visited states
+func (s State) key() string {
+	facts := make([]string, 0, len(s))
+	for fact, value := range s {
+		if value {
+			facts = append(facts, fact)
+		}
+	}
+	slices.Sort(facts)
+	return strings.Join(facts, ",")
+}
+
+type Action struct {
+	Name          string
+	Preconditions State
+	Effects       State
+	Cost          float64
+	// Perform executes the action; Running keeps it active on the next tick,
+	// Success moves the plan to the next action and Failure forces a replan
+	Perform btree.ActionFn
+}
+
+type Goal struct {
+	Name    string
+	Desired State
+	// Priority ranks goals each tick, goals with priority <= 0 are ignored
+	Priority func(*btree.TickContext) float64
+}
+
+type Planner struct {
+	actions []*Action
+	// maxExpansions bounds the search so an impossible goal fails fast
+	maxExpansions int
+}
+
+func NewPlanner(actions ...*Action) *Planner {
+	return &Planner{
+		actions:       actions,
+		maxExpansions: 512,
+	}
+}
+
+func (p *Planner) Actions() []*Action {
+	return p.actions
+}
+
+type searchNode struct {
+	state  State
+	action *Action
+	parent *searchNode
+	cost   float64
+	score  float64 // cost + heuristic
+	index  int
+}
+
+type openSet []*searchNode
+
+func (o openSet) Len() int { return len(o) }
+func (o openSet) Less(i, j int) bool {
+	return o[i].score < o[j].score
+}
+func (o openSet) Swap(i, j int) {
+	o[i], o[j] = o[j], o[i]
+	o[i].index = i
+	o[j].index = j
+}
+func (o *openSet) Push(x any) {
+	n := x.(*searchNode)
+	n.index = len(*o)
+	*o = append(*o, n)
+}
+func (o *openSet) Pop() any {
+	old := *o
+	n := old[len(old)-1]
+	*o = old[:len(old)-1]
+	return n
+}
+
+// Plan returns the cheapest sequence of actions leading from start to a state
+// satisfying goal. An empty plan means the goal is already satisfied.
+func (p *Planner) Plan(start, goal State) ([]*Action, bool) {
+	open := &openSet{}
+	heap.Push(open, &searchNode{state: start, score: float64(start.unsatisfied(goal))})
+	bestCost := map[string]float64{start.key(): 0}
+
+	for expansions := 0; open.Len() > 0 && expansions < p.maxExpansions; expansions++ {
+		current := heap.Pop(open).(*searchNode)
+		if current.state.Satisfies(goal) {
+			var plan []*Action
+			for n := current; n.action != nil; n = n.parent {
+				plan = append(plan, n.action)
+			}
+			slices.Reverse(plan)
+			return plan, true
+		}
+
+		for _, a := range p.actions {
+			if !current.state.Satisfies(a.Preconditions) {
+				continue
+			}
+			next := current.state.Apply(a.Effects)
+			cost := current.cost + a.Cost
+			key := next.key()
+			if c, seen := bestCost[key]; seen && c <= cost {
+				continue
+			}
+			bestCost[key] = cost
+			heap.Push(open, &searchNode{
+				state:  next,
+				action: a,
+				parent: current,
+				cost:   cost,
+				score:  cost + float64(next.unsatisfied(goal)),
+			})
+		}
+	}
+	return nil, false
+}
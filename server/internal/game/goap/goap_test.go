@@ -0,0 +1,88 @@
+package goap
+
+import (
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/game/btree"
+)
+
+func TestPlanCheapest(t *testing.T) {
+	p := NewPlanner(
+		&Action{Name: "scavenge", Effects: State{"has_food": true}, Cost: 8},
+		&Action{Name: "stalk", Effects: State{"near_prey": true}, Cost: 3},
+		&Action{Name: "kill", Preconditions: State{"near_prey": true}, Effects: State{"has_food": true}, Cost: 2},
+	)
+
+	plan, ok := p.Plan(State{}, State{"has_food": true})
+	if !ok {
+		t.Fatal("no plan found")
+	}
+	var names []string
+	for _, a := range plan {
+		names = append(names, a.Name)
+	}
+	if len(names) != 2 || names[0] != "stalk" || names[1] != "kill" {
+		t.Errorf("planned %v, want the cheaper [stalk kill]", names)
+	}
+
+	if plan, ok := p.Plan(State{"has_food": true}, State{"has_food": true}); !ok || len(plan) != 0 {
+		t.Errorf("got %d actions, %v for a satisfied goal", len(plan), ok)
+	}
+	if _, ok := p.Plan(State{}, State{"rested": true}); ok {
+		t.Error("planned a goal no action reaches")
+	}
+}
+
+// A goal that can't be reached must not starve the goals ranked below it
+func TestPlannerNodeFallsThrough(t *testing.T) {
+	var hunts, drinks int
+	p := NewPlanner(
+		&Action{Name: "hunt", Effects: State{"fed": true}, Cost: 1, Perform: func(*btree.TickContext) btree.Status {
+			hunts++
+			return btree.Failure
+		}},
+		&Action{Name: "drink", Effects: State{"hydrated": true}, Cost: 1, Perform: func(*btree.TickContext) btree.Status {
+			drinks++
+			return btree.Running
+		}},
+	)
+	eat := &Goal{Name: "eat", Desired: State{"fed": true}, Priority: func(*btree.TickContext) float64 { return 1 }}
+	drink := &Goal{Name: "drink", Desired: State{"hydrated": true}, Priority: func(*btree.TickContext) float64 { return 0.5 }}
+	node := NewPlannerNode(1, p, func(*btree.TickContext) State { return State{} }, eat, drink)
+	ctx := &btree.TickContext{NodeStates: make([]int, 2)}
+
+	for i := range 3 {
+		if s := node.Tick(ctx); s != btree.Running || node.Goal() != drink {
+			t.Fatalf("tick %d: %v pursuing %v, want running the drink goal", i, s, node.Goal())
+		}
+	}
+	if hunts != 3 || drinks != 3 {
+		t.Errorf("%d hunts, %d drinks, want 3 each", hunts, drinks)
+	}
+}
+
+// A goal whose need drops below its threshold is pursued until it is met
+func TestPlannerNodeKeepsGoal(t *testing.T) {
+	thirst := 90.0
+	p := NewPlanner(&Action{Name: "drink", Effects: State{"hydrated": true}, Cost: 1, Perform: func(*btree.TickContext) btree.Status {
+		thirst -= 20
+		return btree.Running
+	}})
+	drink := &Goal{Name: "drink", Desired: State{"hydrated": true}, Priority: func(*btree.TickContext) float64 {
+		if thirst < 80 {
+			return 0
+		}
+		return thirst / 100
+	}}
+	node := NewPlannerNode(1, p, func(*btree.TickContext) State { return State{"hydrated": thirst < 30} }, drink)
+	ctx := &btree.TickContext{NodeStates: make([]int, 2)}
+
+	for thirst >= 30 {
+		if s := node.Tick(ctx); s != btree.Running {
+			t.Fatalf("gave up at thirst %g", thirst)
+		}
+	}
+	if s := node.Tick(ctx); s != btree.Failure {
+		t.Errorf("still %v once hydrated", s)
+	}
+}
@@ -0,0 +1,145 @@
+package goap
+
+import (
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/game/btree"
+)
+
+type SenseFn func(*btree.TickContext) State
+
+// PlannerNode wraps a planner as a behavior tree node. Each tick it senses
+// the current state, picks the highest priority unsatisfied goal, plans for
+// it when needed and performs the current step of the plan. When the step
+// fails the next ranked goal gets its turn in the same tick. It fails when
+// no goal needs work or none can be planned or performed, so a Selector can
+// fall back to other branches.
+//
+// The active plan is kept on the node, so like the rest of a behavior tree a
+// PlannerNode must not be shared between entities.
+type PlannerNode struct {
+	id      int
+	name    string
+	planner *Planner
+	sense   SenseFn
+	goals   []*Goal
+
+	goal *Goal
+	plan []*Action
+}
+
+func NewPlannerNode(id int, planner *Planner, sense SenseFn, goals ...*Goal) *PlannerNode {
+	return &PlannerNode{
+		id:      id,
+		planner: planner,
+		sense:   sense,
+		goals:   goals,
+	}
+}
+
+func (p *PlannerNode) ID() int {
+	return p.id
+}
+
+func (p *PlannerNode) Name() string {
+	return p.name
+}
+
+func (p *PlannerNode) Kind() btree.Kind {
+	return btree.KindPlanner
+}
+
+func (p *PlannerNode) Children() []btree.Node {
+	return nil
+}
+
+// Named sets a human readable name used by exporters
+func (p *PlannerNode) Named(name string) *PlannerNode {
+	p.name = name
+	return p
+}
+
+// Goal returns the goal being pursued, nil when idle
+func (p *PlannerNode) Goal() *Goal {
+	return p.goal
+}
+
+// Plan returns the remaining actions of the active plan
+func (p *PlannerNode) Plan(ctx *btree.TickContext) []*Action {
+	step := ctx.NodeStates[p.id]
+	if step >= len(p.plan) {
+		return nil
+	}
+	return p.plan[step:]
+}
+
+func (p *PlannerNode) Tick(ctx *btree.TickContext) btree.Status {
+	state := p.sense(ctx)
+	goals := p.rankGoals(ctx, state)
+
+	step := ctx.NodeStates[p.id]
+	valid := p.goal != nil && step < len(p.plan) &&
+		len(goals) > 0 && goals[0] == p.goal &&
+		state.Satisfies(p.plan[step].Preconditions)
+	next := 1 // Ranked goals from here on haven't been tried this tick
+	if !valid {
+		p.goal, p.plan, step, next = nil, nil, 0, 0
+	}
+	for {
+		for ; p.goal == nil && next < len(goals); next++ {
+			if plan, ok := p.planner.Plan(state, goals[next].Desired); ok && len(plan) > 0 {
+				p.goal, p.plan = goals[next], plan
+			}
+		}
+		if p.goal == nil {
+			ctx.NodeStates[p.id] = 0
+			return ctx.Record(p.id, btree.Failure)
+		}
+
+		status := p.plan[step].Perform(ctx)
+		if status == btree.Success && step+1 < len(p.plan) {
+			ctx.NodeStates[p.id] = step + 1
+			return ctx.Record(p.id, btree.Running)
+		}
+		if status == btree.Running {
+			ctx.NodeStates[p.id] = step
+			return ctx.Record(p.id, btree.Running)
+		}
+		// Plan finished or failed, either way the next tick starts fresh
+		p.goal, p.plan, step = nil, nil, 0
+		ctx.NodeStates[p.id] = 0
+		if status == btree.Success {
+			return ctx.Record(p.id, btree.Success)
+		}
+		// A failed goal gives way to the next ranked one, or a goal that
+		// can't be reached would starve every goal below it
+	}
+}
+
+// rankGoals returns unsatisfied goals with positive priority and the one
+// being pursued, highest first
+func (p *PlannerNode) rankGoals(ctx *btree.TickContext, state State) []*Goal {
+	priorities := make(map[*Goal]float64, len(p.goals))
+	ranked := make([]*Goal, 0, len(p.goals))
+	for _, g := range p.goals {
+		if state.Satisfies(g.Desired) {
+			continue
+		}
+		// The goal being pursued stays until it is met, so a need that
+		// drops below its threshold half way isn't abandoned
+		if priority := g.Priority(ctx); priority > 0 || g == p.goal {
+			priorities[g] = priority
+			ranked = append(ranked, g)
+		}
+	}
+	slices.SortStableFunc(ranked, func(a, b *Goal) int {
+		switch {
+		case priorities[a] > priorities[b]:
+			return -1
+		case priorities[a] < priorities[b]:
+			return 1
+		}
+		return 0
+	})
+	return ranked
+}
@@ -7,44 +7,63 @@ import (
 	"github.com/xSaCh/animalia/internal/game/btree"
 )
 
+// Levels at which a goat stops drinking, eating or resting
+const (
+	thirstSated     = 20
+	hungerSated     = 25
+	tirednessRested = 30
+)
+
+// Goat is the blackboard of a goat's behavior tree
 type Goat struct {
-	BaseEntity
+	Agent
 }
 
-// NewGoat creates a new Goat entity with appropriate initial values
-func NewGoat(id int, position common.Vector2D) *Goat {
-	return &Goat{
-		BaseEntity: BaseEntity{
-			ID:       id,
-			Type:     common.EntityTypeGoat,
-			Position: position,
-			State:    common.EntityStateRoaming,
-			Direction: common.Vector2D{
-				X: 0,
-				Y: 0,
-			},
-			TargetPos: nil,
-			Stats: common.Stats{
-				Hunger:    30, // Starting with low hunger (30/100)
-				Thirst:    25, // Starting with low thirst (25/100)
-				Tiredness: 20, // Starting with low tiredness (20/100)
+// SpawnGoat adds a goat with appropriate initial values and returns its ID
+func (w *World) SpawnGoat(position common.Vector2D) int {
+	brain := NewBrain(createGoatBehaviorTree(), &Goat{})
+	return w.Add(Components{
+		Body: Body{
+			Type:   common.EntityTypeGoat,
+			State:  common.EntityStateRoaming,
+			Radius: 0.35,
+		},
+		Transform: Transform{Position: position},
+		Motion: &Motion{
+			Gait: common.GaitWalk,
+			Movement: Movement{
+				WalkSpeed:    3,
+				RunSpeed:     7,
+				Acceleration: 6,
 			},
-			bt:      createGoatBehaviorTree(),
-			btState: make([]int, 20), // Initialize with enough slots for all node IDs
 		},
-	}
+		Stats: &common.Stats{
+			Hunger:    30, // Starting with low hunger (30/100)
+			Thirst:    25, // Starting with low thirst (25/100)
+			Tiredness: 20, // Starting with low tiredness (20/100)
+		},
+		Perception: &Perception{
+			Radius:      8,
+			FOV:         300 * math.Pi / 180, // Goats have eyes on the sides of their head
+			NightVision: 0.5,
+		},
+		Brain: &brain,
+	})
 }
 
 func createGoatBehaviorTree() btree.Node {
 	idGen := btree.NewIDGenerator()
-	
+
 	findWaterSource := func(ctx *btree.TickContext) btree.Status {
 		goat := ctx.BlackBoard.(*Goat)
 		world := ctx.World.(*World)
 
-		// Find nearest water source
-		waterPos := world.GetRandomWaterSourcePos()
-	
+		// Find the free cell by the water that is cheapest to walk to
+		waterPos, dist := world.NearestWaterApproach(goat.Position)
+		if math.IsInf(dist, 1) {
+			return btree.Failure // Every source has dried up
+		}
+
 		goat.TargetPos = &waterPos
 		return btree.Success
 	}
@@ -52,8 +71,11 @@ func createGoatBehaviorTree() btree.Node {
 		goat := ctx.BlackBoard.(*Goat)
 		world := ctx.World.(*World)
 
-		// Find nearest food source
-		foodPos := world.GetRandomFoodSourcePos()
+		// Find the free cell by the food that is cheapest to walk to
+		foodPos, dist := world.NearestFoodApproach(goat.Position)
+		if math.IsInf(dist, 1) {
+			return btree.Failure // Everything is grazed down
+		}
 
 		goat.TargetPos = &foodPos
 		return btree.Success
@@ -62,8 +84,14 @@ func createGoatBehaviorTree() btree.Node {
 		goat := ctx.BlackBoard.(*Goat)
 		world := ctx.World.(*World)
 
-		// Find a random walkable position to rest
-		restPos := world.GetRandomWalkablePosition()
+		// Shelter in the nearest rest area with room. Without one bed down on
+		// the spot at night, or wander off somewhere during the day.
+		restPos := goat.Position
+		if area, dist := world.NearestFreeRestArea(goat.Position); !math.IsInf(dist, 1) {
+			restPos = area
+		} else if !world.IsNight() {
+			restPos = goat.randomWalkablePosition(world)
+		}
 
 		goat.TargetPos = &restPos
 		return btree.Success
@@ -73,8 +101,8 @@ func createGoatBehaviorTree() btree.Node {
 		world := ctx.World.(*World)
 
 		// Find random roaming position
-		if goat.TargetPos == nil || goat.Position.Distance(*goat.TargetPos) < 0.5 {
-			roamPos := world.GetRandomWalkablePosition()
+		if goat.AtTarget() {
+			roamPos := goat.randomWalkablePosition(world)
 			goat.TargetPos = &roamPos
 		}
 		goat.State = common.EntityStateRoaming
@@ -83,18 +111,27 @@ func createGoatBehaviorTree() btree.Node {
 
 	moveToWaterAndDrink := func(ctx *btree.TickContext) btree.Status {
 		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
 
-		// Check if reached target
-		if goat.TargetPos != nil && goat.Position.Distance(*goat.TargetPos) > 0.5 {
-			goat.MoveTowardTarget()
-			goat.updateStatsDuringWalk()
+		// Check if reached target, any spot within reach of the water will do
+		_, dist := world.GetNearestWaterSourcePos(goat.Position)
+		if math.IsInf(dist, 1) {
+			goat.TargetPos = nil
+			return btree.Failure
+		}
+		if dist > interactionRange && !(goat.AtTarget() && dist <= resourceReach) {
+			if goat.AtTarget() {
+				// The water here ran dry, head for the next source
+				next, _ := world.NearestWaterApproach(goat.Position)
+				goat.TargetPos = &next
+			}
+			goat.MoveTowardTarget(world)
 			goat.State = common.EntityStateMoving
 			return btree.Running
 		}
 		// Drink water
 		goat.State = common.EntityStateDrinking
-		goat.Stats.Thirst -= 2
-		if goat.Stats.Thirst <= 20 {
+		if goat.Stats.Thirst <= thirstSated {
 			goat.TargetPos = nil
 			return btree.Success
 		}
@@ -103,19 +140,28 @@ func createGoatBehaviorTree() btree.Node {
 
 	moveToFoodAndEat := func(ctx *btree.TickContext) btree.Status {
 		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
 
-		// Check if reached target
-		if goat.TargetPos != nil && goat.Position.Distance(*goat.TargetPos) > 0.5 {
-			goat.MoveTowardTarget()
-			goat.updateStatsDuringWalk()
+		// Check if reached target, any spot within reach of the food will do
+		_, dist := world.GetNearestFoodSourcePos(goat.Position)
+		if math.IsInf(dist, 1) {
+			goat.TargetPos = nil
+			return btree.Failure
+		}
+		if dist > interactionRange && !(goat.AtTarget() && dist <= resourceReach) {
+			if goat.AtTarget() {
+				// The food here ran out, head for the next source
+				next, _ := world.NearestFoodApproach(goat.Position)
+				goat.TargetPos = &next
+			}
+			goat.MoveTowardTarget(world)
 			goat.State = common.EntityStateMoving
 			return btree.Running
 		}
 
 		// Eat food
 		goat.State = common.EntityStateEating
-		goat.Stats.Hunger -= 2
-		if goat.Stats.Hunger <= 25 {
+		if goat.Stats.Hunger <= hungerSated {
 			goat.TargetPos = nil
 			return btree.Success
 		}
@@ -124,22 +170,48 @@ func createGoatBehaviorTree() btree.Node {
 
 	moveToRestingSpotAndRest := func(ctx *btree.TickContext) btree.Status {
 		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
 
-		// Check if reached resting spot
-		if goat.TargetPos != nil && goat.Position.Distance(*goat.TargetPos) > 0.5 {
-			goat.MoveTowardTarget()
-			goat.updateStatsDuringWalk()
+		// Check if reached resting spot, anywhere inside a rest area will do
+		if !goat.AtTarget() && world.restAreaAt(goat.Position) < 0 {
+			goat.MoveTowardTarget(world)
 			goat.State = common.EntityStateMoving
 			return btree.Running
 		}
 
-		// Rest
+		// Rest, through the whole night once it has fallen
 		goat.State = common.EntityStateResting
-		goat.Stats.Tiredness -= 2
-		if goat.Stats.Tiredness <= 30 {
+		if goat.Stats.Tiredness <= tirednessRested && !world.IsNight() {
+			goat.TargetPos = nil
+			return btree.Success
+		}
+		return btree.Running
+	}
+
+	fleeFromWolf := func(ctx *btree.TickContext) btree.Status {
+		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
+
+		wolf, _ := goat.NearestVisible(world, common.EntityTypeWolf)
+		if wolf == nil {
+			goat.State = common.EntityStateIdle
 			goat.TargetPos = nil
 			return btree.Success
 		}
+
+		// Run a few cells straight away from the wolf, staying inside the world
+		away := goat.Position.Subtract(wolf.Position).Normalize()
+		if away.IsZero() {
+			away = common.Vector2D{X: 1}
+		}
+		fleePos := goat.Position.Add(away.Scale(5))
+		fleePos.X = math.Min(world.Width-1, math.Max(0, fleePos.X))
+		fleePos.Y = math.Min(world.Height-1, math.Max(0, fleePos.Y))
+
+		goat.TargetPos = &fleePos
+		goat.State = common.EntityStateFleeing
+		goat.Gait = common.GaitRun
+		goat.MoveTowardTarget(world)
 		return btree.Running
 	}
 
@@ -148,72 +220,107 @@ func createGoatBehaviorTree() btree.Node {
 		world := ctx.World.(*World)
 
 		// Check if reached target pos
-		if goat.TargetPos != nil && goat.Position.Distance(*goat.TargetPos) > 0.5 {
-			goat.MoveTowardTarget()
-			goat.updateStatsDuringWalk()
+		if !goat.AtTarget() {
+			goat.MoveTowardTarget(world)
 			goat.State = common.EntityStateMoving
 			return btree.Running
 		}
-		
+
+		// Linger for up to two seconds before picking the next roaming spot
 		goat.State = common.EntityStateIdle
-		if world.GetTick()%40 == 0 {
+		if world.GetTick()%uint(2*world.Config.TPS) == 0 {
 			return btree.Success
 		}
 		return btree.Running
 	}
 
-	return btree.NewSelector(idGen.Next(),
+	// Needs are scored instead of checked against fixed thresholds, so a
+	// starving goat eats before drinking even if it is also thirsty
+	// A need being satisfied keeps a high score until its action completes,
+	// otherwise the goat walks off as soon as another need edges ahead
+	needCurve := btree.Logistic(10, 0.75)
+	const committed = 0.9
+	thirstScore := func(ctx *btree.TickContext) float64 {
+		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
+		if goat.State == common.EntityStateDrinking && goat.Stats.Thirst > thirstSated {
+			return committed
+		}
+		dist := world.NearestWaterDistance(goat.Position)
+		if math.IsInf(dist, 1) {
+			return 0
+		}
+		return needCurve(goat.Stats.Thirst/100) * world.distanceFactor(dist)
+	}
+	hungerScore := func(ctx *btree.TickContext) float64 {
+		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
+		if goat.State == common.EntityStateEating && goat.Stats.Hunger > hungerSated {
+			return committed
+		}
+		dist := world.NearestFoodDistance(goat.Position)
+		if math.IsInf(dist, 1) {
+			return 0
+		}
+		return needCurve(goat.Stats.Hunger/100) * world.distanceFactor(dist)
+	}
+	// Goats bed down at night even when not tired, strong needs still win
+	const nightRest = 0.6
+	tirednessScore := func(ctx *btree.TickContext) float64 {
+		goat := ctx.BlackBoard.(*Goat)
+		if goat.State == common.EntityStateResting && goat.Stats.Tiredness > tirednessRested {
+			return committed
+		}
+		score := needCurve(goat.Stats.Tiredness / 100)
+		if ctx.World.(*World).IsNight() {
+			score = max(score, nightRest)
+		}
+		return score
+	}
+	// Danger goes above 1 so a close wolf interrupts even a committed need
+	dangerCurve := btree.Inverse(btree.Linear(1.5, -0.5))
+	dangerScore := func(ctx *btree.TickContext) float64 {
+		goat := ctx.BlackBoard.(*Goat)
+		world := ctx.World.(*World)
+		wolf, dist := goat.NearestVisible(world, common.EntityTypeWolf)
+		if wolf == nil || goat.Sheltered(world) {
+			return 0 // Safe in a rest area, stay put
+		}
+		return 1.5 * dangerCurve(dist/goat.Perception.Range)
+	}
+
+	return btree.NewUtilitySelector(idGen.Next(), 0.15,
+		// Run from visible wolves
+		btree.Option{Score: dangerScore, Node: btree.NewAction(idGen.Next(), fleeFromWolf).Named("flee")},
+
 		// Handle Thirst
-		btree.NewSequence(idGen.Next(),
-			btree.NewCondition(idGen.Next(), func(ctx *btree.TickContext) bool {
-				return ctx.BlackBoard.(*Goat).Stats.Thirst >= 80
-			}),
-			btree.NewAction(idGen.Next(), findWaterSource),
-			btree.NewAction(idGen.Next(), moveToWaterAndDrink),
-		),
+		btree.Option{Score: thirstScore, Node: btree.NewSequence(idGen.Next(),
+			btree.NewAction(idGen.Next(), findWaterSource).Named("find_water"),
+			btree.NewAction(idGen.Next(), moveToWaterAndDrink).Named("move_to_water_and_drink"),
+		).Named("drink")},
 
 		// Handle Hunger
-		btree.NewSequence(idGen.Next(),
-			btree.NewCondition(idGen.Next(), func(ctx *btree.TickContext) bool {
-				return ctx.BlackBoard.(*Goat).Stats.Hunger >= 80
-			}),
-			btree.NewAction(idGen.Next(), findFoodSource),
-			btree.NewAction(idGen.Next(), moveToFoodAndEat),
-		),
+		btree.Option{Score: hungerScore, Node: btree.NewSequence(idGen.Next(),
+			btree.NewAction(idGen.Next(), findFoodSource).Named("find_food"),
+			btree.NewAction(idGen.Next(), moveToFoodAndEat).Named("move_to_food_and_eat"),
+		).Named("eat")},
 
 		// Handle Tiredness
-		btree.NewSequence(idGen.Next(),
-			btree.NewCondition(idGen.Next(), func(ctx *btree.TickContext) bool {
-				return ctx.BlackBoard.(*Goat).Stats.Tiredness >= 80
-			}),
-			btree.NewAction(idGen.Next(), findRestingSpot),
-			btree.NewAction(idGen.Next(), moveToRestingSpotAndRest),
-		),
+		btree.Option{Score: tirednessScore, Node: btree.NewSequence(idGen.Next(),
+			btree.NewAction(idGen.Next(), findRestingSpot).Named("find_resting_spot"),
+			btree.NewAction(idGen.Next(), moveToRestingSpotAndRest).Named("move_to_resting_spot_and_rest"),
+		).Named("rest")},
 
 		// default roaming
-		btree.NewSequence(idGen.Next(),
-			btree.NewAction(idGen.Next(), findRoamingPosition),
-			btree.NewAction(idGen.Next(), moveWhileRoaming),
-		),
-	)
+		btree.Option{Score: btree.Constant(0.15), Node: btree.NewSequence(idGen.Next(),
+			btree.NewAction(idGen.Next(), findRoamingPosition).Named("find_roam_pos"),
+			btree.NewAction(idGen.Next(), moveWhileRoaming).Named("roam_to_pos"),
+		).Named("roam")},
+	).Named("goat")
 }
 
-func (g *Goat) updateStatsDuringWalk() {
-	g.Stats.Hunger += 1
-	g.Stats.Thirst += 2
-	g.Stats.Tiredness += 1
-
-	g.Stats.Hunger = int8(math.Min(100, math.Max(0, float64(g.Stats.Hunger))))
-	g.Stats.Thirst = int8(math.Min(100, math.Max(0, float64(g.Stats.Thirst))))
-	g.Stats.Tiredness = int8(math.Min(100, math.Max(0, float64(g.Stats.Tiredness))))
-}
-
-// Tick executes the ent's behavior tree
-func (g *Goat) Tick(world *World) {
-	ctx := &btree.TickContext{
-		BlackBoard: g,
-		World:      world,
-		NodeStates: g.btState,
-	}
-	g.bt.Tick(ctx)
+// distanceFactor discounts a need's score by up to 25% for far away resources
+func (w *World) distanceFactor(dist float64) float64 {
+	diagonal := math.Hypot(w.Width, w.Height)
+	return 1 - 0.25*btree.Normalize(dist, 0, diagonal)
 }
@@ -0,0 +1,303 @@
+package game
+
+import (
+	"container/heap"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Hierarchical pathfinding (HPA*) splits the grid into square clusters.
+// Wherever the border between two clusters is open, a transition links a
+// cell on each side, and within a cluster every pair of transition cells is
+// linked by the cost of the cheapest path between them. Long searches run
+// over that small graph first and then fill in the cell steps one cluster
+// at a time. Map edits only rebuild the clusters whose cells changed.
+
+const (
+	clusterSize = 16
+
+	// Open stretches of border up to this long get one transition in the
+	// middle, longer ones one at each end
+	maxSingleEntrance = 6
+)
+
+type hpaGraph struct {
+	cols, rows int
+	clusters   []hpaCluster
+	dirty      map[int]bool // Clusters to rebuild before the next search
+}
+
+type hpaCluster struct {
+	bounds area
+	nodes  []cell             // Transition cells, sorted so rebuilds are deterministic
+	edges  map[cell][]hpaEdge // From each node to the others and across borders
+}
+
+type hpaEdge struct {
+	to   cell
+	cost float64
+}
+
+// buildHierarchy builds the cluster graph of the whole map
+func (w *World) buildHierarchy() {
+	width, height := len(w.NavigationGrid[0]), len(w.NavigationGrid)
+	h := &hpaGraph{
+		cols:  (width + clusterSize - 1) / clusterSize,
+		rows:  (height + clusterSize - 1) / clusterSize,
+		dirty: map[int]bool{},
+	}
+	h.clusters = make([]hpaCluster, h.cols*h.rows)
+	for k := range h.clusters {
+		cx, cy := k%h.cols, k/h.cols
+		h.clusters[k].bounds = area{
+			minX: cx * clusterSize,
+			minY: cy * clusterSize,
+			maxX: min((cx+1)*clusterSize, width) - 1,
+			maxY: min((cy+1)*clusterSize, height) - 1,
+		}
+	}
+	w.hpa = h
+	w.parallel(len(h.clusters), w.rebuildCluster)
+}
+
+// clusterOf returns the index of the cluster containing c
+func (h *hpaGraph) clusterOf(c cell) int {
+	return c.Y/clusterSize*h.cols + c.X/clusterSize
+}
+
+// neighbors returns the clusters sharing a border with k
+func (h *hpaGraph) neighbors(k int) []int {
+	cx, cy := k%h.cols, k/h.cols
+	var n []int
+	if cx > 0 {
+		n = append(n, k-1)
+	}
+	if cx < h.cols-1 {
+		n = append(n, k+1)
+	}
+	if cy > 0 {
+		n = append(n, k-h.cols)
+	}
+	if cy < h.rows-1 {
+		n = append(n, k+h.cols)
+	}
+	return n
+}
+
+// markDirty schedules the clusters affected by a change to cell (x, y): its
+// own and, when it sits on a border, the cluster across
+func (h *hpaGraph) markDirty(x, y int) {
+	if h == nil {
+		return // Still generating the map, the graph is built afterwards
+	}
+	c := cell{x, y}
+	k := h.clusterOf(c)
+	h.dirty[k] = true
+	b := h.clusters[k].bounds
+	for _, n := range h.neighbors(k) {
+		nb := h.clusters[n].bounds
+		if (nb.maxX < b.minX && x == b.minX) || (nb.minX > b.maxX && x == b.maxX) ||
+			(nb.maxY < b.minY && y == b.minY) || (nb.minY > b.maxY && y == b.maxY) {
+			h.dirty[n] = true
+		}
+	}
+}
+
+// updateHierarchy rebuilds the clusters marked dirty since the last update
+func (w *World) updateHierarchy() {
+	if w.hpa == nil {
+		return
+	}
+	dirty := slices.Collect(maps.Keys(w.hpa.dirty))
+	w.parallel(len(dirty), func(i int) { w.rebuildCluster(dirty[i]) })
+	clear(w.hpa.dirty)
+}
+
+// rebuildCluster recomputes a cluster's transitions and the costs between
+// them. It only writes the cluster itself, so clusters rebuild in parallel.
+func (w *World) rebuildCluster(k int) {
+	h := w.hpa
+	c := &h.clusters[k]
+	c.nodes = c.nodes[:0]
+	c.edges = map[cell][]hpaEdge{}
+	for _, n := range h.neighbors(k) {
+		for _, t := range w.transitions(c.bounds, h.clusters[n].bounds) {
+			if _, ok := c.edges[t[0]]; !ok {
+				c.nodes = append(c.nodes, t[0])
+			}
+			d := cell{t[1].X - t[0].X, t[1].Y - t[0].Y}
+			c.edges[t[0]] = append(c.edges[t[0]], hpaEdge{to: t[1], cost: w.stepCost(d, t[1])})
+		}
+	}
+	slices.SortFunc(c.nodes, func(a, b cell) int {
+		if a.Y != b.Y {
+			return a.Y - b.Y
+		}
+		return a.X - b.X
+	})
+	for _, from := range c.nodes {
+		costs := w.costsFrom(from, c.bounds)
+		for _, to := range c.nodes {
+			if cost := costs[c.bounds.index(to)]; !math.IsInf(cost, 1) && to != from {
+				c.edges[from] = append(c.edges[from], hpaEdge{to: to, cost: cost})
+			}
+		}
+	}
+}
+
+// transitions returns pairs of cells, the first in a and the second in the
+// neighboring cluster b, where the border between them can be crossed. The
+// same pairs come back mirrored when called from b's side.
+func (w *World) transitions(a, b area) [][2]cell {
+	var fixed, across, lo, hi int
+	vertical := b.minX > a.maxX || b.maxX < a.minX // Border runs along a column
+	switch {
+	case b.minX > a.maxX:
+		fixed, across, lo, hi = a.maxX, b.minX, a.minY, a.maxY
+	case b.maxX < a.minX:
+		fixed, across, lo, hi = a.minX, b.maxX, a.minY, a.maxY
+	case b.minY > a.maxY:
+		fixed, across, lo, hi = a.maxY, b.minY, a.minX, a.maxX
+	default:
+		fixed, across, lo, hi = a.minY, b.maxY, a.minX, a.maxX
+	}
+	pair := func(i int) [2]cell {
+		if vertical {
+			return [2]cell{{fixed, i}, {across, i}}
+		}
+		return [2]cell{{i, fixed}, {i, across}}
+	}
+	open := func(i int) bool {
+		p := pair(i)
+		return w.IsWalkable(p[0].X, p[0].Y) && w.IsWalkable(p[1].X, p[1].Y)
+	}
+
+	var pairs [][2]cell
+	for i := lo; i <= hi; i++ {
+		if !open(i) {
+			continue
+		}
+		start := i
+		for i+1 <= hi && open(i+1) {
+			i++
+		}
+		if i-start+1 <= maxSingleEntrance {
+			pairs = append(pairs, pair((start+i)/2))
+		} else {
+			pairs = append(pairs, pair(start), pair(i))
+		}
+	}
+	return pairs
+}
+
+// FindPathHierarchical is FindPath for long trips. It searches the cluster
+// graph and then refines each hop with A* inside one cluster, so the path
+// can be slightly longer than the optimal one.
+func (w *World) FindPathHierarchical(from, to common.Vector2D) ([]common.Vector2D, bool) {
+	start, goal, ok := w.pathEnds(from, to)
+	if !ok {
+		return nil, false
+	}
+	if start == goal {
+		return nil, true
+	}
+	h := w.hpa
+	startCluster, goalCluster := h.clusterOf(start), h.clusterOf(goal)
+	if startCluster == goalCluster {
+		if cells, _, ok := w.astar(start, goal, h.clusters[startCluster].bounds); ok {
+			return cellCenters(cells), true
+		}
+	}
+
+	// Hook start and goal into the graph without touching the shared clusters
+	startEdges := w.entryEdges(start, startCluster)
+	goalEdges := w.entryEdges(goal, goalCluster)
+
+	hops, ok := h.search(start, goal, startEdges, goalEdges)
+	if !ok {
+		return nil, false
+	}
+	var cells []cell
+	for i := 1; i < len(hops); i++ {
+		a, b := hops[i-1], hops[i]
+		if k := h.clusterOf(a); k == h.clusterOf(b) {
+			segment, _, ok := w.astar(a, b, h.clusters[k].bounds)
+			if !ok {
+				return nil, false
+			}
+			cells = append(cells, segment...)
+		} else {
+			cells = append(cells, b) // Border crossing, a single step
+		}
+	}
+	return cellCenters(cells), true
+}
+
+// entryEdges links a cell to the transition nodes of its cluster. The goal
+// uses them backwards, terrain makes costs differ slightly by direction but
+// refining the hops finds the exact cells anyway.
+func (w *World) entryEdges(c cell, k int) []hpaEdge {
+	cluster := &w.hpa.clusters[k]
+	costs := w.costsFrom(c, cluster.bounds)
+	var edges []hpaEdge
+	for _, n := range cluster.nodes {
+		if cost := costs[cluster.bounds.index(n)]; !math.IsInf(cost, 1) && n != c {
+			edges = append(edges, hpaEdge{to: n, cost: cost})
+		}
+	}
+	return edges
+}
+
+// search runs A* over the cluster graph from start to goal and returns the
+// cells it hops through, start and goal included. goalEdges link nodes to
+// the goal, keyed by node.
+func (h *hpaGraph) search(start, goal cell, startEdges, goalEdges []hpaEdge) ([]cell, bool) {
+	toGoal := map[cell]float64{}
+	for _, e := range goalEdges {
+		toGoal[e.to] = e.cost
+	}
+	edgesOf := func(c cell) []hpaEdge {
+		edges := h.clusters[h.clusterOf(c)].edges[c]
+		if c == start {
+			// A start on a transition keeps its border crossings
+			edges = append(slices.Clip(edges), startEdges...)
+		}
+		if cost, ok := toGoal[c]; ok {
+			edges = append(slices.Clip(edges), hpaEdge{to: goal, cost: cost})
+		}
+		return edges
+	}
+
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{cell: start, score: octile(start, goal)})
+	cost := map[cell]float64{start: 0}
+	cameFrom := map[cell]cell{}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*pathNode)
+		if current.cell == goal {
+			hops := []cell{goal}
+			for c := goal; c != start; {
+				c = cameFrom[c]
+				hops = append(hops, c)
+			}
+			slices.Reverse(hops)
+			return hops, true
+		}
+		if current.cost > cost[current.cell] {
+			continue
+		}
+		for _, e := range edgesOf(current.cell) {
+			nextCost := current.cost + e.cost
+			if c, seen := cost[e.to]; seen && c <= nextCost {
+				continue
+			}
+			cost[e.to] = nextCost
+			cameFrom[e.to] = current.cell
+			heap.Push(open, &pathNode{cell: e.to, cost: nextCost, score: nextCost + octile(e.to, goal)})
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,250 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Map edits keep the navigation grid, line of sight, obstacle lists and
+// cached paths in step with each other and emit an event so observers know
+// to pick up the new map. Like Add outside of intents they
+// must be called between ticks, from the goroutine that ticks the world.
+
+var (
+	ErrOutOfBounds  = errors.New("outside the map")
+	ErrCellOccupied = errors.New("cell already holds an obstacle")
+	ErrNoObstacle   = errors.New("no obstacle there")
+)
+
+// AddObstacle places a wall, water source or food source on a cell. Water
+// and food start full.
+func (w *World) AddObstacle(t common.ObstacleType, x, y int) error {
+	o, err := newObstacle(t, x, y)
+	if err != nil {
+		return err
+	}
+	if err := w.placeObstacle(o); err != nil {
+		return err
+	}
+	w.mapChanged(Event{Kind: EventObstacleAdded, Obstacle: t, Position: o.Position})
+	return nil
+}
+
+// AddObstacleShape places a wall, water source or food source covering every
+// cell inside shape, see RectShape for rectangles
+func (w *World) AddObstacleShape(t common.ObstacleType, shape []common.Vector2D) error {
+	o, err := newShapedObstacle(t, shape)
+	if err != nil {
+		return err
+	}
+	if err := w.placeObstacle(o); err != nil {
+		return err
+	}
+	w.mapChanged(Event{Kind: EventObstacleAdded, Obstacle: t, Position: o.Position})
+	return nil
+}
+
+// RemoveObstacle clears the wall, water or food source covering a cell and
+// makes every cell it covered walkable again
+func (w *World) RemoveObstacle(x, y int) (common.StaticObstacle, error) {
+	list, i := w.obstacleAt(x, y)
+	if list == nil {
+		return common.StaticObstacle{}, fmt.Errorf("remove obstacle at (%d, %d): %w", x, y, ErrNoObstacle)
+	}
+	o := w.clearObstacle(list, i)
+	w.mapChanged(Event{Kind: EventObstacleRemoved, Obstacle: o.Type, Position: o.Position})
+	return o, nil
+}
+
+// AddRestArea adds a shelter zone centered on pos. Rest areas don't block
+// the grid.
+func (w *World) AddRestArea(pos common.Vector2D, radius float64, capacity int) error {
+	if !w.inBounds(cellOf(pos)) {
+		return fmt.Errorf("add rest area at %v: %w", pos, ErrOutOfBounds)
+	}
+	if radius <= 0 || capacity <= 0 {
+		return fmt.Errorf("add rest area at %v: radius and capacity must be positive", pos)
+	}
+	w.StaticObstacles.RestAreas = append(w.StaticObstacles.RestAreas, NewRestArea(pos, radius, capacity))
+	w.mapChanged(Event{Kind: EventObstacleAdded, Obstacle: common.ObstacleTypeRestArea, Position: pos})
+	return nil
+}
+
+// RemoveRestArea removes the rest area containing pos
+func (w *World) RemoveRestArea(pos common.Vector2D) (common.StaticObstacle, error) {
+	i := w.restAreaAt(pos)
+	if i < 0 {
+		return common.StaticObstacle{}, fmt.Errorf("remove rest area at %v: %w", pos, ErrNoObstacle)
+	}
+	areas := &w.StaticObstacles.RestAreas
+	o := (*areas)[i]
+	*areas = slices.Delete(*areas, i, i+1)
+	w.mapChanged(Event{Kind: EventObstacleRemoved, Obstacle: o.Type, Position: o.Position})
+	return o, nil
+}
+
+// PaintWalkable sets the walkability of every cell in the rectangle from
+// (x0, y0) to (x1, y1) inclusive, clipped to the map. Cells holding an
+// obstacle keep it and stay blocked. It returns how many cells changed.
+func (w *World) PaintWalkable(x0, y0, x1, y1 int, walkable bool) int {
+	x0, x1 = max(min(x0, x1), 0), min(max(x0, x1), len(w.NavigationGrid[0])-1)
+	y0, y1 = max(min(y0, y1), 0), min(max(y0, y1), len(w.NavigationGrid)-1)
+	changed := 0
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if w.NavigationGrid[y][x] == walkable {
+				continue
+			}
+			if list, _ := w.obstacleAt(x, y); list != nil {
+				continue
+			}
+			w.setWalkable(x, y, walkable)
+			changed++
+		}
+	}
+	if changed > 0 {
+		w.mapChanged(Event{
+			Kind:     EventMapPainted,
+			Position: common.Vector2D{X: float64(x0), Y: float64(y0)},
+			Size:     &common.Vector2D{X: float64(x1 - x0 + 1), Y: float64(y1 - y0 + 1)},
+			Walkable: &walkable,
+		})
+	}
+	return changed
+}
+
+func newObstacle(t common.ObstacleType, x, y int) (common.StaticObstacle, error) {
+	o := common.StaticObstacle{Type: t, Position: common.Vector2D{X: float64(x), Y: float64(y)}}
+	switch t {
+	case common.ObstacleTypeWall:
+	case common.ObstacleTypeWaterSource:
+		o.Amount, o.Capacity = waterCapacity, waterCapacity
+	case common.ObstacleTypeFoodSource:
+		o.Amount, o.Capacity = foodCapacity, foodCapacity
+	default:
+		return o, fmt.Errorf("add obstacle: unknown obstacle type %q", t)
+	}
+	return o, nil
+}
+
+// placeObstacle adds o to its list and blocks the cells it covers, walls
+// also block line of sight. Map generation uses it directly since there is
+// no one to notify yet.
+func (w *World) placeObstacle(o common.StaticObstacle) error {
+	cells := obstacleCells(o)
+	for _, c := range cells {
+		if !w.inBounds(c.X, c.Y) {
+			return fmt.Errorf("add %s at (%d, %d): %w", o.Type, c.X, c.Y, ErrOutOfBounds)
+		}
+		if list, _ := w.obstacleAt(c.X, c.Y); list != nil {
+			return fmt.Errorf("add %s at (%d, %d): %w", o.Type, c.X, c.Y, ErrCellOccupied)
+		}
+	}
+	list := w.obstacleList(o.Type)
+	*list = append(*list, o)
+	for _, c := range cells {
+		w.setWalkable(c.X, c.Y, false)
+		w.opaque[c.Y][c.X] = o.Type == common.ObstacleTypeWall
+	}
+	return nil
+}
+
+// clearObstacle takes the obstacle at index i off its list and frees the
+// cells it covered
+func (w *World) clearObstacle(list *[]common.StaticObstacle, i int) common.StaticObstacle {
+	o := (*list)[i]
+	*list = slices.Delete(*list, i, i+1)
+	for _, c := range obstacleCells(o) {
+		w.setWalkable(c.X, c.Y, true)
+		w.opaque[c.Y][c.X] = false
+	}
+	return o
+}
+
+// obstacleList returns the list holding obstacles of type t
+func (w *World) obstacleList(t common.ObstacleType) *[]common.StaticObstacle {
+	switch t {
+	case common.ObstacleTypeWall:
+		return &w.StaticObstacles.Walls
+	case common.ObstacleTypeWaterSource:
+		return &w.StaticObstacles.WaterSources
+	case common.ObstacleTypeFoodSource:
+		return &w.StaticObstacles.FoodSources
+	case common.ObstacleTypeRestArea:
+		return &w.StaticObstacles.RestAreas
+	}
+	return nil
+}
+
+// obstacleAt finds the wall, water or food source covering a cell,
+// returning its list and index or a nil list when the cell is free
+func (w *World) obstacleAt(x, y int) (*[]common.StaticObstacle, int) {
+	for _, t := range []common.ObstacleType{common.ObstacleTypeWall, common.ObstacleTypeWaterSource, common.ObstacleTypeFoodSource} {
+		list := w.obstacleList(t)
+		for i, o := range *list {
+			if covers(o, x, y) {
+				return list, i
+			}
+		}
+	}
+	return nil, 0
+}
+
+// setWalkable is the one place map edits change the navigation grid
+func (w *World) setWalkable(x, y int, walkable bool) {
+	w.NavigationGrid[y][x] = walkable
+	w.hpa.markDirty(x, y)
+	w.markFlowDirty(x, y)
+}
+
+func (w *World) inBounds(x, y int) bool {
+	return y >= 0 && y < len(w.NavigationGrid) && x >= 0 && x < len(w.NavigationGrid[y])
+}
+
+// mapChanged publishes a new map with the next snapshot, relabels regions
+// and drops every cached path, routes through the edited cells may no longer
+// exist. Entities left standing on a cell that was just blocked are moved
+// off it.
+func (w *World) mapChanged(e Event) {
+	w.mapVersion++
+	w.labelRegions()
+	w.updateHierarchy()
+	w.updateFlowFields()
+	for _, m := range w.Motions.All() {
+		m.path, m.pathValid = nil, false
+	}
+	for _, t := range w.Transforms.All() {
+		x, y := cellOf(t.Position)
+		if w.IsWalkable(x, y) {
+			continue
+		}
+		if c, ok := w.nearestWalkableCell(cell{x, y}); ok {
+			t.Position = common.Vector2D{X: float64(c.X), Y: float64(c.Y)}
+		}
+	}
+	w.emit(e)
+}
+
+// nearestWalkableCell searches outward from c for the closest walkable cell
+func (w *World) nearestWalkableCell(c cell) (cell, bool) {
+	seen := map[cell]bool{c: true}
+	queue := []cell{c}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if w.IsWalkable(cur.X, cur.Y) {
+			return cur, true
+		}
+		for _, d := range neighborOffsets[:4] {
+			next := cell{cur.X + d.X, cur.Y + d.Y}
+			if !seen[next] && w.inBounds(next.X, next.Y) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return cell{}, false
+}
@@ -0,0 +1,57 @@
+package game
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+func gridsEqual(a, b [][]bool) bool {
+	return slices.EqualFunc(a, b, func(x, y []bool) bool { return slices.Equal(x, y) })
+}
+
+// Removing an obstacle puts back the walkability and line of sight it took
+func TestAddRemoveObstacle(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	grid, opaque := cloneGrid(w.NavigationGrid), cloneGrid(w.opaque)
+	left, right := common.Vector2D{X: 5.5, Y: 10.5}, common.Vector2D{X: 15.5, Y: 10.5}
+
+	if err := w.AddObstacleShape(common.ObstacleTypeWall, RectShape(10, 0, 10, 19)); err != nil {
+		t.Fatal(err)
+	}
+	if w.IsWalkable(10, 7) || w.HasLineOfSight(left, right) || w.Connected(left, right) {
+		t.Error("wall across the map doesn't block")
+	}
+	if err := w.AddObstacle(common.ObstacleTypeWaterSource, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	if w.IsWalkable(3, 3) || !w.HasLineOfSight(common.Vector2D{X: 1.5, Y: 3.5}, common.Vector2D{X: 5.5, Y: 3.5}) {
+		t.Error("water should block walking but not sight")
+	}
+
+	if err := w.AddObstacle(common.ObstacleTypeFoodSource, 10, 4); !errors.Is(err, ErrCellOccupied) {
+		t.Errorf("adding onto the wall: %v", err)
+	}
+	if err := w.AddObstacle(common.ObstacleTypeWall, 20, 4); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("adding off the map: %v", err)
+	}
+	if _, err := w.RemoveObstacle(4, 4); !errors.Is(err, ErrNoObstacle) {
+		t.Errorf("removing from an empty cell: %v", err)
+	}
+
+	// Any cell of the wall removes all of it
+	if o, err := w.RemoveObstacle(10, 12); err != nil || o.Type != common.ObstacleTypeWall {
+		t.Fatalf("removed %v, %v", o.Type, err)
+	}
+	if _, err := w.RemoveObstacle(3, 3); err != nil {
+		t.Fatal(err)
+	}
+	if !gridsEqual(w.NavigationGrid, grid) || !gridsEqual(w.opaque, opaque) {
+		t.Error("grid or opacity not restored")
+	}
+	if !w.HasLineOfSight(left, right) || !w.Connected(left, right) {
+		t.Error("removed wall still blocks")
+	}
+}
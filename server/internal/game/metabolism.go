@@ -0,0 +1,99 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// StatRates are per second changes to each stat, negative values recover
+type StatRates struct {
+	Hunger    float64 `json:"hunger"`
+	Thirst    float64 `json:"thirst"`
+	Tiredness float64 `json:"tiredness"`
+}
+
+// Metabolism maps each entity state to the stat rates applied while in it.
+// States missing from the map don't change stats.
+type Metabolism map[common.EntityState]StatRates
+
+// gaitCost multiplies the stat costs (positive rates) of moving for each gait
+var gaitCost = map[common.Gait]float64{
+	common.GaitWalk: 1,
+	common.GaitRun:  2,
+}
+
+// DefaultMetabolism returns the built-in rates for every species
+func DefaultMetabolism() map[common.EntityType]Metabolism {
+	return map[common.EntityType]Metabolism{
+		common.EntityTypeGoat: {
+			common.EntityStateIdle:     {Hunger: 0.4, Thirst: 0.6, Tiredness: 0.2},
+			common.EntityStateRoaming:  {Hunger: 1, Thirst: 2, Tiredness: 1},
+			common.EntityStateMoving:   {Hunger: 1, Thirst: 2, Tiredness: 1},
+			common.EntityStateFleeing:  {Hunger: 1, Thirst: 2, Tiredness: 1},
+			common.EntityStateDrinking: {Hunger: 0.4, Thirst: -30, Tiredness: 0.2},
+			common.EntityStateEating:   {Hunger: -30, Thirst: 0.6, Tiredness: 0.2},
+			common.EntityStateResting:  {Hunger: 0.4, Thirst: 0.6, Tiredness: -25},
+		},
+		common.EntityTypeWolf: {
+			common.EntityStateIdle:     {Hunger: 0.5, Thirst: 0.4, Tiredness: 0.2},
+			common.EntityStateRoaming:  {Hunger: 1, Thirst: 1.5, Tiredness: 1},
+			common.EntityStateMoving:   {Hunger: 1, Thirst: 1.5, Tiredness: 1},
+			common.EntityStateDrinking: {Hunger: 0.5, Thirst: -30, Tiredness: 0.2},
+			common.EntityStateEating:   {Hunger: -30, Thirst: 0.4, Tiredness: 0.2},
+			common.EntityStateResting:  {Hunger: 0.5, Thirst: 0.4, Tiredness: -25},
+		},
+	}
+}
+
+// applyMetabolism updates every entity's stats for the state it ended the
+// tick in, scaled by the tick's delta time so rates don't depend on TPS
+func (w *World) applyMetabolism() {
+	dt := w.DeltaTime()
+	weather := w.weatherEffect().Metabolism
+	for id, stats := range w.Stats.All() {
+		body := w.Bodies.Get(id)
+		if body == nil {
+			continue
+		}
+		rates, ok := w.Config.Metabolism[body.Type][body.State]
+		if !ok {
+			continue
+		}
+		cost := 1.0
+		if m := w.Motions.Get(id); m != nil && m.Speed > 0 {
+			cost = gaitCost[m.Gait]
+		}
+		hunger := scaleRate(rates.Hunger, cost*weather.Hunger) * dt
+		thirst := scaleRate(rates.Thirst, cost*weather.Thirst) * dt
+		tiredness := scaleRate(rates.Tiredness, cost*weather.Tiredness) * dt
+		if v := w.View(id); v != nil && v.Sheltered && tiredness < 0 {
+			tiredness *= shelterRecovery
+		}
+
+		// Eating and drinking only recover what the meal, or the food or
+		// water in reach, holds
+		if hunger < 0 && body.Meal > 0 {
+			eaten := math.Min(body.Meal, -hunger)
+			body.Meal -= eaten
+			hunger = -eaten
+		} else if t := w.Transforms.Get(id); t != nil && hunger < 0 {
+			hunger = -consume(w.StaticObstacles.FoodSources, t.Position, -hunger)
+		}
+		if t := w.Transforms.Get(id); t != nil && thirst < 0 {
+			thirst = -consume(w.StaticObstacles.WaterSources, t.Position, -thirst)
+		}
+		stats.Hunger += hunger
+		stats.Thirst += thirst
+		stats.Tiredness += tiredness
+		stats.Clamp()
+	}
+}
+
+// scaleRate applies the gait cost to stat costs only, recovery is unaffected
+func scaleRate(rate, cost float64) float64 {
+	if rate > 0 {
+		return rate * cost
+	}
+	return rate
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+func TestMetabolismRates(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.weather.Weather = WeatherClear
+	if err := w.AddObstacle(common.ObstacleTypeWaterSource, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	dt := w.DeltaTime()
+	start := common.Stats{Hunger: 50, Thirst: 50, Tiredness: 50}
+
+	tests := []struct {
+		name  string
+		t     common.EntityType
+		state common.EntityState
+		pos   common.Vector2D
+		gait  common.Gait
+		meal  float64
+		want  common.Stats // Change per second
+	}{
+		{"idle goat", common.EntityTypeGoat, common.EntityStateIdle, common.Vector2D{X: 3.5, Y: 3.5}, "", 0, common.Stats{Hunger: 0.4, Thirst: 0.6, Tiredness: 0.2}},
+		{"running goat", common.EntityTypeGoat, common.EntityStateFleeing, common.Vector2D{X: 3.5, Y: 3.5}, common.GaitRun, 0, common.Stats{Hunger: 2, Thirst: 4, Tiredness: 2}},
+		{"drinking far from water", common.EntityTypeGoat, common.EntityStateDrinking, common.Vector2D{X: 3.5, Y: 3.5}, "", 0, common.Stats{Hunger: 0.4, Tiredness: 0.2}},
+		{"drinking at water", common.EntityTypeGoat, common.EntityStateDrinking, common.Vector2D{X: 9.5, Y: 10.5}, "", 0, common.Stats{Hunger: 0.4, Thirst: -30, Tiredness: 0.2}},
+		{"wolf eating a meal", common.EntityTypeWolf, common.EntityStateEating, common.Vector2D{X: 3.5, Y: 3.5}, "", 80, common.Stats{Hunger: -30, Thirst: 0.4, Tiredness: 0.2}},
+		{"wolf eating nothing", common.EntityTypeWolf, common.EntityStateEating, common.Vector2D{X: 3.5, Y: 3.5}, "", 0, common.Stats{Thirst: 0.4, Tiredness: 0.2}},
+	}
+	for _, tt := range tests {
+		var id int
+		if tt.t == common.EntityTypeWolf {
+			id = w.SpawnWolf(tt.pos)
+		} else {
+			id = w.SpawnGoat(tt.pos)
+		}
+		body, stats, m := w.Bodies.Get(id), w.Stats.Get(id), w.Motions.Get(id)
+		body.State, body.Meal, *stats = tt.state, tt.meal, start
+		if tt.gait != "" {
+			m.Gait, m.Speed = tt.gait, 1
+		}
+		w.captureView()
+		w.applyMetabolism()
+
+		got := common.Stats{Hunger: stats.Hunger - 50, Thirst: stats.Thirst - 50, Tiredness: stats.Tiredness - 50}
+		if math.Abs(got.Hunger-tt.want.Hunger*dt) > 1e-9 || math.Abs(got.Thirst-tt.want.Thirst*dt) > 1e-9 ||
+			math.Abs(got.Tiredness-tt.want.Tiredness*dt) > 1e-9 {
+			t.Errorf("%s: stats changed by %+v in a tick, want %+v per second", tt.name, got, tt.want)
+		}
+		if tt.meal > 0 && math.Abs(body.Meal-(tt.meal-30*dt)) > 1e-9 {
+			t.Errorf("%s: %g of the meal left", tt.name, body.Meal)
+		}
+		w.removeComponents(id)
+	}
+	if water := w.StaticObstacles.WaterSources[0]; math.Abs(water.Amount-(waterCapacity-30*dt)) > 1e-9 {
+		t.Errorf("water source holds %g after a drink", water.Amount)
+	}
+}
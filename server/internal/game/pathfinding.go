@@ -0,0 +1,308 @@
+package game
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+type cell struct {
+	X, Y int
+}
+
+// center returns the point in the middle of the cell, where waypoints are
+func (c cell) center() common.Vector2D {
+	return common.Vector2D{X: float64(c.X) + 0.5, Y: float64(c.Y) + 0.5}
+}
+
+var neighborOffsets = []cell{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+// canStep reports whether an entity can move from c to the neighbor c+d.
+// Diagonal steps need both orthogonal cells free so paths never cut corners.
+func (w *World) canStep(c, d cell) bool {
+	if !w.IsWalkable(c.X+d.X, c.Y+d.Y) {
+		return false
+	}
+	if d.X != 0 && d.Y != 0 {
+		return w.IsWalkable(c.X+d.X, c.Y) && w.IsWalkable(c.X, c.Y+d.Y)
+	}
+	return true
+}
+
+// octile is the exact cost between cells on an empty 8-connected grid
+func octile(a, b cell) float64 {
+	dx, dy := math.Abs(float64(a.X-b.X)), math.Abs(float64(a.Y-b.Y))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+type pathNode struct {
+	cell  cell
+	cost  float64
+	score float64
+	index int
+}
+
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	return q[i].score < q[j].score
+}
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *pathQueue) Push(x any) {
+	n := x.(*pathNode)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+func (q *pathQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// FindPath returns the centers of the cells to walk through, excluding from
+// and including to, using A* over the navigation grid. It returns false when
+// to is blocked or cannot be reached.
+func (w *World) FindPath(from, to common.Vector2D) ([]common.Vector2D, bool) {
+	start, goal, ok := w.pathEnds(from, to)
+	if !ok {
+		return nil, false
+	}
+	if start == goal {
+		return nil, true
+	}
+	cells, _, ok := w.astar(start, goal, w.bounds())
+	return cellCenters(cells), ok
+}
+
+// findPath picks plain A* for short trips and the hierarchical pathfinder
+// for long ones, where a full grid search gets expensive
+func (w *World) findPath(from, to common.Vector2D) ([]common.Vector2D, bool) {
+	if from.Distance(to) > 2*clusterSize {
+		return w.FindPathHierarchical(from, to)
+	}
+	return w.FindPath(from, to)
+}
+
+// pathEnds returns the cells a path runs between, false when none can exist
+func (w *World) pathEnds(from, to common.Vector2D) (cell, cell, bool) {
+	start, goal := cell{}, cell{}
+	start.X, start.Y = cellOf(from)
+	goal.X, goal.Y = cellOf(to)
+	if !w.IsWalkable(goal.X, goal.Y) {
+		return start, goal, false
+	}
+	// Entities pushed onto a blocked cell have no region, let them search
+	if r := w.Region(from); r != 0 && r != w.Region(to) {
+		return start, goal, false
+	}
+	return start, goal, true
+}
+
+// area is a rectangle of cells, inclusive on both ends
+type area struct {
+	minX, minY, maxX, maxY int
+}
+
+func (a area) contains(c cell) bool {
+	return c.X >= a.minX && c.X <= a.maxX && c.Y >= a.minY && c.Y <= a.maxY
+}
+
+// grow returns the area extended by n cells on every side
+func (a area) grow(n int) area {
+	return area{a.minX - n, a.minY - n, a.maxX + n, a.maxY + n}
+}
+
+// clip returns the part of the area inside b
+func (a area) clip(b area) area {
+	return area{max(a.minX, b.minX), max(a.minY, b.minY), min(a.maxX, b.maxX), min(a.maxY, b.maxY)}
+}
+
+// overlaps reports whether the areas share a cell
+func (a area) overlaps(b area) bool {
+	return a.minX <= b.maxX && b.minX <= a.maxX && a.minY <= b.maxY && b.minY <= a.maxY
+}
+
+// bounds returns the whole map
+func (w *World) bounds() area {
+	return area{0, 0, len(w.NavigationGrid[0]) - 1, len(w.NavigationGrid) - 1}
+}
+
+// stepCost is the cost of moving by d into next, slow terrain costs more.
+// Modifiers are at most 1 so octile stays an admissible heuristic.
+func (w *World) stepCost(d, next cell) float64 {
+	step := 1.0
+	if d.X != 0 && d.Y != 0 {
+		step = math.Sqrt2
+	}
+	return step / w.SpeedModifier(next.X, next.Y)
+}
+
+func (a area) width() int  { return a.maxX - a.minX + 1 }
+func (a area) height() int { return a.maxY - a.minY + 1 }
+
+// index returns the position of c in a slice laid out row by row over a
+func (a area) index(c cell) int {
+	return (c.Y-a.minY)*a.width() + c.X - a.minX
+}
+
+func (a area) cellAt(i int) cell {
+	return cell{a.minX + i%a.width(), a.minY + i/a.width()}
+}
+
+// astar searches for the cheapest path from start to goal without leaving
+// bounds. It returns the cells after start up to and including goal and the
+// path's cost.
+func (w *World) astar(start, goal cell, bounds area) ([]cell, float64, bool) {
+	r := searchPool.Get().(*searchResult)
+	defer searchPool.Put(r)
+	w.search(r, start, bounds, func(c cell) float64 { return octile(c, goal) }, goal)
+	if !bounds.contains(goal) {
+		return nil, 0, false
+	}
+	i := bounds.index(goal)
+	cost := r.cost(i)
+	if math.IsInf(cost, 1) {
+		return nil, 0, false
+	}
+	var path []cell
+	for c := goal; c != start; c = bounds.cellAt(r.from[bounds.index(c)]) {
+		path = append(path, c)
+	}
+	slices.Reverse(path)
+	return path, cost, true
+}
+
+// costsFrom returns the cost of the cheapest path from start to every cell
+// of bounds, infinite for cells it can't reach (Dijkstra). Costs are laid
+// out with bounds.index.
+func (w *World) costsFrom(start cell, bounds area) []float64 {
+	r := searchPool.Get().(*searchResult)
+	defer searchPool.Put(r)
+	w.search(r, start, bounds, func(cell) float64 { return 0 }, cell{-1, -1})
+	costs := make([]float64, bounds.width()*bounds.height())
+	for i := range costs {
+		costs[i] = r.cost(i)
+	}
+	return costs
+}
+
+// searchResult holds the per cell state of a search. A search over the whole
+// map would otherwise allocate megabytes even for a short trip, so results
+// are pooled and a search only resets the cells it reaches: those not
+// stamped with its generation haven't been reached.
+type searchResult struct {
+	costs []float64
+	from  []int    // Index of the previous cell on the cheapest path
+	stamp []uint32 // Generation that last reached each cell
+	gen   uint32
+}
+
+var searchPool = sync.Pool{New: func() any { return &searchResult{} }}
+
+// reset starts a new search over n cells
+func (r *searchResult) reset(n int) {
+	if len(r.stamp) < n {
+		r.costs = make([]float64, n)
+		r.from = make([]int, n)
+		r.stamp = make([]uint32, n)
+		r.gen = 0
+	}
+	r.gen++
+	if r.gen == 0 { // Wrapped around, old stamps could match again
+		clear(r.stamp)
+		r.gen = 1
+	}
+}
+
+// cost returns the cheapest cost found to the cell at index i
+func (r *searchResult) cost(i int) float64 {
+	if r.stamp[i] != r.gen {
+		return math.Inf(1)
+	}
+	return r.costs[i]
+}
+
+func (r *searchResult) set(i int, cost float64, from int) {
+	r.costs[i], r.from[i], r.stamp[i] = cost, from, r.gen
+}
+
+// search expands cells from start in order of cost plus heuristic until it
+// reaches goal or runs out of cells
+func (w *World) search(r *searchResult, start cell, bounds area, heuristic func(cell) float64, goal cell) {
+	r.reset(bounds.width() * bounds.height())
+	if !bounds.contains(start) {
+		return
+	}
+	r.set(bounds.index(start), 0, 0)
+
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{cell: start, score: heuristic(start)})
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*pathNode)
+		if current.cell == goal {
+			break
+		}
+		ci := bounds.index(current.cell)
+		if current.cost > r.cost(ci) {
+			continue // Stale entry, a cheaper route was queued later
+		}
+		for _, d := range neighborOffsets {
+			next := cell{current.cell.X + d.X, current.cell.Y + d.Y}
+			if !bounds.contains(next) || !w.canStep(current.cell, d) {
+				continue
+			}
+			ni := bounds.index(next)
+			nextCost := current.cost + w.stepCost(d, next)
+			if r.cost(ni) <= nextCost {
+				continue
+			}
+			r.set(ni, nextCost, ci)
+			heap.Push(open, &pathNode{cell: next, cost: nextCost, score: nextCost + heuristic(next)})
+		}
+	}
+}
+
+// cellCenters turns a path of cells into waypoints
+func cellCenters(cells []cell) []common.Vector2D {
+	if len(cells) == 0 {
+		return nil
+	}
+	path := make([]common.Vector2D, len(cells))
+	for i, c := range cells {
+		path[i] = c.center()
+	}
+	return path
+}
+
+// ApproachPoint returns where to stand to use the resource at target, which
+// is a cell or a point on a lake's edge: the center of the target's cell if
+// walkable, otherwise that of the walkable neighbor closest to from
+func (w *World) ApproachPoint(target, from common.Vector2D) common.Vector2D {
+	tx, ty := cellOf(target)
+	if w.IsWalkable(tx, ty) {
+		return cell{tx, ty}.center()
+	}
+	best, bestDist := target, math.Inf(1)
+	for _, d := range neighborOffsets {
+		if !w.IsWalkable(tx+d.X, ty+d.Y) {
+			continue
+		}
+		p := cell{tx + d.X, ty + d.Y}.center()
+		if dist := p.Distance(from); dist < bestDist {
+			best, bestDist = p, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,230 @@
+package game
+
+import (
+	"maps"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// newMazeWorld returns a size x size world with wall segments over about a
+// fifth of the map
+func newMazeWorld(tb testing.TB, size int) *World {
+	tb.Helper()
+	cfg := DefaultConfig(20)
+	cfg.Seed = 42
+	w := NewWorldWithConfig(size, cfg)
+	rng := rand.New(rand.NewPCG(42, 1))
+	for range size * size / 40 {
+		x, y, length := rng.IntN(size), rng.IntN(size), 3+rng.IntN(12)
+		for i := range length {
+			if rng.IntN(2) == 0 && x+i < size {
+				w.NavigationGrid[y][x+i] = false
+			} else if y+i < size {
+				w.NavigationGrid[y+i][x] = false
+			}
+		}
+	}
+	w.labelRegions()
+	w.buildHierarchy()
+	w.buildFlowFields()
+	return w
+}
+
+// farPairs returns connected start and goal positions at least minDist apart
+func farPairs(tb testing.TB, w *World, n int, minDist float64) [][2]common.Vector2D {
+	tb.Helper()
+	rng := rand.New(rand.NewPCG(7, 7))
+	var pairs [][2]common.Vector2D
+	for len(pairs) < n {
+		a, err := w.randomPositionIn(rng, 0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		b, _ := w.randomPositionIn(rng, 0)
+		if a.Distance(b) >= minDist {
+			pairs = append(pairs, [2]common.Vector2D{a, b})
+		}
+	}
+	return pairs
+}
+
+func pathCost(w *World, from common.Vector2D, path []common.Vector2D) float64 {
+	cost := 0.0
+	prev := cell{}
+	prev.X, prev.Y = cellOf(from)
+	for _, p := range path {
+		c := cell{}
+		c.X, c.Y = cellOf(p)
+		cost += w.stepCost(cell{c.X - prev.X, c.Y - prev.Y}, c)
+		prev = c
+	}
+	return cost
+}
+
+func TestFindPathHierarchical(t *testing.T) {
+	w := newMazeWorld(t, 200)
+	check := func(from, to common.Vector2D) {
+		t.Helper()
+		optimal, ok := w.FindPath(from, to)
+		path, hok := w.FindPathHierarchical(from, to)
+		if ok != hok {
+			t.Fatalf("%v to %v: A* found a path %v, hierarchical %v", from, to, ok, hok)
+		}
+		if !ok {
+			return
+		}
+		prev := cell{}
+		prev.X, prev.Y = cellOf(from)
+		for _, p := range path {
+			c := cell{}
+			c.X, c.Y = cellOf(p)
+			d := cell{c.X - prev.X, c.Y - prev.Y}
+			if max(abs(d.X), abs(d.Y)) != 1 || !w.canStep(prev, d) {
+				t.Fatalf("%v to %v: invalid step from %v to %v", from, to, prev, c)
+			}
+			prev = c
+		}
+		if x, y := cellOf(to); prev != (cell{x, y}) {
+			t.Fatalf("%v to %v: path ends at %v", from, to, prev)
+		}
+		if got, want := pathCost(w, from, path), pathCost(w, from, optimal); got > want*1.25+1 {
+			t.Errorf("%v to %v: hierarchical path costs %.1f, optimal %.1f", from, to, got, want)
+		}
+	}
+
+	pairs := farPairs(t, w, 50, 40)
+	for _, p := range pairs {
+		check(p[0], p[1])
+	}
+
+	// Cut the map in two, paths must follow the edit
+	w.PaintWalkable(0, 100, 199, 100, false)
+	for _, p := range pairs {
+		check(p[0], p[1])
+	}
+	w.PaintWalkable(95, 100, 105, 100, true)
+	for _, p := range pairs {
+		check(p[0], p[1])
+	}
+}
+
+func TestFlowFields(t *testing.T) {
+	w := newMazeWorld(t, 120)
+	check := func() {
+		t.Helper()
+		rng := rand.New(rand.NewPCG(3, 3))
+		for range 50 {
+			from, err := w.randomPositionIn(rng, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shore, cost := w.NearestWaterApproach(from)
+			if math.IsInf(cost, 1) {
+				continue
+			}
+			if _, dist := w.GetNearestWaterSourcePos(shore); dist > resourceReach {
+				t.Fatalf("%v: approach %v is %.1f from water", from, shore, dist)
+			}
+			path, ok := w.FindPath(from, shore)
+			if !ok {
+				t.Fatalf("%v: no path to approach %v", from, shore)
+			}
+			if got := pathCost(w, from, path); math.Abs(got-cost) > 1e-9 {
+				t.Errorf("%v: field says %.2f to %v, path costs %.2f", from, cost, shore, got)
+			}
+		}
+	}
+	check()
+
+	// Wall off the left of the map, fields must follow the edit
+	w.PaintWalkable(40, 0, 40, 119, false)
+	check()
+}
+
+// An edit rebuilds the fields within reach of it and keeps the others
+func TestFlowFieldsLocalRebuild(t *testing.T) {
+	w := newMazeWorld(t, 300)
+	before := maps.Clone(w.flows)
+	key := cell{}
+	key.X, key.Y = cellOf(w.StaticObstacles.WaterSources[0].Position)
+	x, y := w.flows[key].bounds.minX, w.flows[key].bounds.minY
+	for !w.IsWalkable(x, y) {
+		x++
+	}
+	w.PaintWalkable(x, y, x, y, false)
+
+	rebuilt := 0
+	for key, f := range w.flows {
+		side := flowRadius*2 + 1
+		if f.bounds.width() > side+16 || f.bounds.height() > side+16 {
+			t.Errorf("field of %v covers %dx%d cells", key, f.bounds.width(), f.bounds.height())
+		}
+		covers := f.bounds.contains(cell{x, y})
+		if covers == (f == before[key]) {
+			t.Errorf("field of %v covering the edit %v was rebuilt %v", key, covers, f != before[key])
+		}
+		if covers {
+			rebuilt++
+		}
+	}
+	if rebuilt == 0 || rebuilt == len(w.flows) {
+		t.Errorf("rebuilt %d of %d fields", rebuilt, len(w.flows))
+	}
+}
+
+func benchmarkPaths(b *testing.B, find func(w *World, from, to common.Vector2D) ([]common.Vector2D, bool)) {
+	w := newMazeWorld(b, 500)
+	pairs := farPairs(b, w, 64, 150)
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		p := pairs[i%len(pairs)]
+		if _, ok := find(w, p[0], p[1]); !ok {
+			b.Fatalf("no path from %v to %v", p[0], p[1])
+		}
+	}
+}
+
+func BenchmarkFindPath500(b *testing.B) {
+	benchmarkPaths(b, (*World).FindPath)
+}
+
+func BenchmarkFindPathHierarchical500(b *testing.B) {
+	benchmarkPaths(b, (*World).FindPathHierarchical)
+}
+
+// BenchmarkMapEdit500 toggles a wall, rebuilding the regions and the
+// clusters it touches
+func BenchmarkMapEdit500(b *testing.B) {
+	w := newMazeWorld(b, 500)
+	for i := 0; b.Loop(); i++ {
+		x, y := 250+i%2, 250
+		w.PaintWalkable(x, y, x, y, !w.IsWalkable(x, y))
+	}
+}
+
+func BenchmarkBuildHierarchy500(b *testing.B) {
+	w := newMazeWorld(b, 500)
+	for b.Loop() {
+		w.buildHierarchy()
+	}
+}
+
+// Standing at the approach point of a single cell resource, from any side,
+// is close enough to use it
+func TestApproachPointInRange(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	if err := w.AddObstacle(common.ObstacleTypeWaterSource, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	water := w.StaticObstacles.WaterSources[0].Position
+	for _, d := range neighborOffsets {
+		from := common.Vector2D{X: 10.5 + 5*float64(d.X), Y: 10.5 + 5*float64(d.Y)}
+		at := w.ApproachPoint(water, from)
+		if _, dist := w.GetNearestWaterSourcePos(at); dist > interactionRange {
+			t.Errorf("approaching from %v stops at %v, %.2f from the water", from, at, dist)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Perception describes what an entity can sense and what it saw on the
+// current tick. The visible lists are refreshed right before the entity
+// ticks, so behavior tree conditions can read them directly.
+type Perception struct {
+	Radius      float64 `json:"radius"`
+	FOV         float64 `json:"fov"`          // Field of view in radians centered on Direction, 2π sees all around
+	NightVision float64 `json:"night_vision"` // Fraction of Radius still seen in full darkness
+
+	Range            float64                 `json:"range"` // Radius reduced by darkness this tick
+	VisibleEntities  []int                   `json:"visible_entities"`
+	VisibleResources []common.StaticObstacle `json:"visible_resources"`
+}
+
+// InView reports whether target lies inside the range and view cone of an
+// entity at pos facing dir. A zero dir (never moved) sees all around.
+func (p *Perception) InView(pos, dir, target common.Vector2D) bool {
+	offset := target.Subtract(pos)
+	dist := offset.Length()
+	if dist > p.Range {
+		return false
+	}
+	if dist == 0 || dir.IsZero() || p.FOV >= 2*math.Pi {
+		return true
+	}
+	cos := offset.Dot(dir) / (dist * dir.Length())
+	return cos >= math.Cos(p.FOV/2)
+}
+
+// Sees reports whether the entity saw the entity with the given ID this tick
+func (e *Agent) Sees(id int) bool {
+	for _, v := range e.Perception.VisibleEntities {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// NearestVisible returns the closest visible entity of the given type, as
+// it was at the start of the tick, and its distance, or nil when none is in sight
+func (e *Agent) NearestVisible(world *World, t common.EntityType) (*EntityView, float64) {
+	return e.NearestVisibleFunc(world, func(v *EntityView) bool { return v.Type == t })
+}
+
+// NearestVisibleFunc is NearestVisible for entities matching a predicate
+func (e *Agent) NearestVisibleFunc(world *World, match func(*EntityView) bool) (*EntityView, float64) {
+	var nearest *EntityView
+	best := math.Inf(1)
+	for _, id := range e.Perception.VisibleEntities {
+		o := world.View(id)
+		if o == nil || !match(o) {
+			continue
+		}
+		if d := e.Position.Distance(o.Position); d < best {
+			nearest, best = o, d
+		}
+	}
+	return nearest, best
+}
+
+// perceive refreshes an entity's visible entities and resources from the
+// start of tick views, it only writes the entity's own perception
+func (w *World) perceive(id int, e *Transform, p *Perception, light float64) {
+	p.VisibleEntities = p.VisibleEntities[:0]
+	p.VisibleResources = p.VisibleResources[:0]
+	p.Range = p.Radius * (p.NightVision + (1-p.NightVision)*light)
+	if p.Range <= 0 {
+		return
+	}
+
+	// Areas leave out their far edge, the margin keeps entities right at
+	// the range in
+	for _, i := range w.view.grid.query(AreaAround(e.Position, p.Range+1), w.viewPosition) {
+		o := &w.view.entities[i]
+		if o.ID == id || !p.InView(e.Position, e.Direction, o.Position) {
+			continue
+		}
+		if w.HasLineOfSight(e.Position, o.Position) {
+			p.VisibleEntities = append(p.VisibleEntities, o.ID)
+		}
+	}
+	for _, r := range w.resources {
+		// Lakes are seen when any part of them is
+		edge, _ := closestPoint(r, e.Position)
+		if p.InView(e.Position, e.Direction, edge) && w.HasLineOfSight(e.Position, edge) {
+			p.VisibleResources = append(p.VisibleResources, r)
+		}
+	}
+}
+
+// HasLineOfSight walks the grid cells between from and to (Bresenham) and
+// reports whether none of them hold a wall. The end cells themselves never
+// block, so an entity standing next to a wall can still see past its corner.
+func (w *World) HasLineOfSight(from, to common.Vector2D) bool {
+	x0, y0 := cellOf(from)
+	x1, y1 := cellOf(to)
+
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	x, y := x0, y0
+	for x != x1 || y != y1 {
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+		if (x != x1 || y != y1) && w.blocksSight(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// blocksSight reports whether the cell holds a wall. Water and food are not
+// walkable but stay see-through.
+func (w *World) blocksSight(x, y int) bool {
+	if y < 0 || y >= len(w.opaque) || x < 0 || x >= len(w.opaque[y]) {
+		return true
+	}
+	return w.opaque[y][x]
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
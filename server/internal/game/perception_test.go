@@ -0,0 +1,49 @@
+package game
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+func TestPerceive(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	if err := w.AddObstacleShape(common.ObstacleTypeWall, RectShape(8, 12, 8, 14)); err != nil {
+		t.Fatal(err)
+	}
+	viewer := w.SpawnGoat(common.Vector2D{X: 5.5, Y: 10.5})
+	ahead := w.SpawnGoat(common.Vector2D{X: 9.5, Y: 10.5})
+	far := w.SpawnGoat(common.Vector2D{X: 12.5, Y: 10.5})
+	behind := w.SpawnGoat(common.Vector2D{X: 2.5, Y: 10.5})
+	walled := w.SpawnGoat(common.Vector2D{X: 10.5, Y: 13.5})
+	tr := w.Transforms.Get(viewer)
+	tr.Direction = common.Vector2D{X: 1}
+	w.captureView()
+
+	p := &Perception{Radius: 8, FOV: math.Pi / 2, NightVision: 0.5}
+	tests := []struct {
+		name  string
+		light float64
+		want  []int
+	}{
+		{"day", 1, []int{ahead, far}},
+		{"night", 0, []int{ahead}}, // Half the range
+	}
+	for _, tt := range tests {
+		w.perceive(viewer, tr, p, tt.light)
+		if !slices.Equal(p.VisibleEntities, tt.want) {
+			t.Errorf("%s: sees %v, want %v, not %d behind or %d past the wall", tt.name, p.VisibleEntities, tt.want, behind, walled)
+		}
+	}
+
+	// Without the wall the goat past it is in sight
+	if _, err := w.RemoveObstacle(8, 12); err != nil {
+		t.Fatal(err)
+	}
+	w.perceive(viewer, tr, p, 1)
+	if !slices.Contains(p.VisibleEntities, walled) {
+		t.Errorf("sees %v once the wall is gone, want %d", p.VisibleEntities, walled)
+	}
+}
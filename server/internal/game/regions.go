@@ -0,0 +1,194 @@
+package game
+
+import (
+	"errors"
+	"math/rand/v2"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// ErrNoWalkableCell is returned when there is nowhere left to stand
+var ErrNoWalkableCell = errors.New("no walkable cell")
+
+// regionMap labels every walkable cell with the connected region it belongs
+// to, using the same steps as pathfinding so two cells share a region
+// exactly when a path exists between them. It is rebuilt between ticks
+// whenever the map changes and only read during ticks.
+type regionMap struct {
+	labels [][]int        // 0 for blocked cells, regions count from 1
+	sizes  []int          // Cells per region, indexed by label
+	main   int            // Largest region, where spawns and resources go
+	shores map[cell][]int // Regions next to each resource, keyed by its position's cell
+}
+
+// labelRegions flood fills the walkable grid into regions
+func (w *World) labelRegions() {
+	r := regionMap{
+		labels: make([][]int, len(w.NavigationGrid)),
+		sizes:  []int{0},
+		shores: map[cell][]int{},
+	}
+	for y, row := range w.NavigationGrid {
+		r.labels[y] = make([]int, len(row))
+	}
+	var queue []cell
+	for y, row := range w.NavigationGrid {
+		for x, walkable := range row {
+			if !walkable || r.labels[y][x] != 0 {
+				continue
+			}
+			label := len(r.sizes)
+			r.sizes = append(r.sizes, 0)
+			r.labels[y][x] = label
+			queue = append(queue[:0], cell{x, y})
+			for len(queue) > 0 {
+				c := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				r.sizes[label]++
+				for _, d := range neighborOffsets {
+					next := cell{c.X + d.X, c.Y + d.Y}
+					if w.canStep(c, d) && r.labels[next.Y][next.X] == 0 {
+						r.labels[next.Y][next.X] = label
+						queue = append(queue, next)
+					}
+				}
+			}
+			if r.sizes[label] > r.sizes[r.main] {
+				r.main = label
+			}
+		}
+	}
+
+	for _, list := range [][]common.StaticObstacle{w.StaticObstacles.WaterSources, w.StaticObstacles.FoodSources} {
+		for _, o := range list {
+			key := cell{}
+			key.X, key.Y = cellOf(o.Position)
+			seen := map[int]bool{}
+			for _, c := range obstacleCells(o) {
+				for _, d := range neighborOffsets {
+					if l := r.label(c.X+d.X, c.Y+d.Y); l != 0 && !seen[l] {
+						seen[l] = true
+						r.shores[key] = append(r.shores[key], l)
+					}
+				}
+			}
+		}
+	}
+	w.regions = r
+}
+
+func (r *regionMap) label(x, y int) int {
+	if y < 0 || y >= len(r.labels) || x < 0 || x >= len(r.labels[y]) {
+		return 0
+	}
+	return r.labels[y][x]
+}
+
+// Region returns the connected region of the cell containing pos, 0 when
+// it is blocked or off the map
+func (w *World) Region(pos common.Vector2D) int {
+	return w.regions.label(cellOf(pos))
+}
+
+// Connected reports whether a path exists between a and b
+func (w *World) Connected(a, b common.Vector2D) bool {
+	ra := w.Region(a)
+	return ra != 0 && ra == w.Region(b)
+}
+
+// reachable reports whether the resource o can be used from region. Entities
+// stuck on a blocked cell have no region and may try any resource.
+func (w *World) reachable(o common.StaticObstacle, region int) bool {
+	if region == 0 {
+		return true
+	}
+	key := cell{}
+	key.X, key.Y = cellOf(o.Position)
+	for _, r := range w.regions.shores[key] {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// randomPositionIn draws a cell of region, or of the main region when region
+// is 0
+func (w *World) randomPositionIn(rng *rand.Rand, region int) (common.Vector2D, error) {
+	if region == 0 {
+		region = w.regions.main
+	}
+	if region == 0 || region >= len(w.regions.sizes) {
+		return common.Vector2D{}, ErrNoWalkableCell
+	}
+	height, width := len(w.regions.labels), len(w.regions.labels[0])
+
+	// Rejection sampling finds a cell quickly unless the region is tiny,
+	// then fall back to picking one of its cells directly
+	for range 64 {
+		x, y := rng.IntN(width), rng.IntN(height)
+		if w.regions.labels[y][x] == region {
+			return common.Vector2D{X: float64(x), Y: float64(y)}, nil
+		}
+	}
+	n := rng.IntN(w.regions.sizes[region])
+	for y, row := range w.regions.labels {
+		for x, l := range row {
+			if l != region {
+				continue
+			}
+			if n == 0 {
+				return common.Vector2D{X: float64(x), Y: float64(y)}, nil
+			}
+			n--
+		}
+	}
+	return common.Vector2D{}, ErrNoWalkableCell
+}
+
+// connectResources moves generated water and food that ended up walled off
+// from the main region onto open ground inside it. The new cell has all its
+// neighbors free, so blocking it cannot split the region.
+func (w *World) connectResources(rng *rand.Rand) {
+	for _, t := range []common.ObstacleType{common.ObstacleTypeWaterSource, common.ObstacleTypeFoodSource} {
+		list := w.obstacleList(t)
+		for i := 0; i < len(*list); i++ {
+			o := (*list)[i]
+			if w.reachable(o, w.regions.main) {
+				continue
+			}
+			moved := false
+			for range 64 {
+				pos, err := w.randomPositionIn(rng, w.regions.main)
+				if err != nil {
+					break
+				}
+				x, y := cellOf(pos)
+				if !w.openAround(x, y) {
+					continue
+				}
+				w.clearObstacle(list, i)
+				o, _ = newObstacle(t, x, y)
+				w.placeObstacle(o)
+				moved = true
+				break
+			}
+			if moved {
+				i-- // The moved source went to the end of the list
+				w.labelRegions()
+			}
+		}
+	}
+}
+
+// openAround reports whether a cell and all its neighbors are walkable
+func (w *World) openAround(x, y int) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if !w.IsWalkable(x+dx, y+dy) {
+				return false
+			}
+		}
+	}
+	return true
+}
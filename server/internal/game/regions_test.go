@@ -0,0 +1,84 @@
+package game
+
+import (
+	"errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// newEmptyWorld returns a size x size world with the generated obstacles
+// cleared, so every cell is walkable
+func newEmptyWorld(tb testing.TB, size int) *World {
+	tb.Helper()
+	cfg := DefaultConfig(20)
+	cfg.Seed = 3
+	w := NewWorldWithConfig(size, cfg)
+	for _, t := range []common.ObstacleType{common.ObstacleTypeWall, common.ObstacleTypeWaterSource, common.ObstacleTypeFoodSource} {
+		list := w.obstacleList(t)
+		for len(*list) > 0 {
+			w.clearObstacle(list, 0)
+		}
+	}
+	w.mapChanged(Event{Kind: EventObstacleRemoved})
+	return w
+}
+
+// Nothing can spawn on a map without a walkable cell
+func TestRegionsFullyBlocked(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.PaintWalkable(0, 0, 19, 19, false)
+	if pos, err := w.GetRandomWalkablePosition(); !errors.Is(err, ErrNoWalkableCell) {
+		t.Fatalf("got %v, %v on a blocked map", pos, err)
+	}
+}
+
+func TestRegionsConnected(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	a, b := common.Vector2D{X: 2, Y: 2}, common.Vector2D{X: 17, Y: 17}
+	if !w.Connected(a, b) {
+		t.Fatal("open map not connected")
+	}
+
+	w.PaintWalkable(10, 0, 10, 19, false)
+	if w.Connected(a, b) {
+		t.Error("connected across a wall")
+	}
+	if wall := (common.Vector2D{X: 10, Y: 5}); w.Connected(wall, wall) {
+		t.Error("blocked cell connected to itself")
+	}
+
+	w.PaintWalkable(10, 5, 10, 5, true)
+	if !w.Connected(a, b) {
+		t.Error("not connected through a gap in the wall")
+	}
+}
+
+// A food source generated inside a walled pocket is moved where the main
+// region reaches it
+func TestConnectResourcesMovesIslandedSource(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.PaintWalkable(0, 0, 4, 4, false)
+	w.PaintWalkable(1, 1, 3, 3, true)
+	o, _ := newObstacle(common.ObstacleTypeFoodSource, 2, 2)
+	if err := w.placeObstacle(o); err != nil {
+		t.Fatal(err)
+	}
+	w.labelRegions()
+	if w.reachable(o, w.regions.main) {
+		t.Fatal("source in the pocket reachable from the main region")
+	}
+
+	w.connectResources(rand.New(rand.NewPCG(1, 1)))
+	food := w.StaticObstacles.FoodSources
+	if len(food) != 1 {
+		t.Fatalf("%d food sources, want 1", len(food))
+	}
+	if food[0].Position == o.Position || !w.reachable(food[0], w.regions.main) {
+		t.Errorf("source at %v not moved into the main region", food[0].Position)
+	}
+	if !w.IsWalkable(2, 2) {
+		t.Error("old cell still blocked")
+	}
+}
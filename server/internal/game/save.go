@@ -0,0 +1,307 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game/btree"
+	"github.com/xSaCh/animalia/internal/game/ecs"
+)
+
+// saveVersion changes whenever Save stops being readable by older code
+const saveVersion = 2
+
+var ErrBadSave = errors.New("invalid save")
+
+// Save is everything needed to resume a world where it left off. Derived
+// state, such as regions, cluster graphs and flow fields, is rebuilt when
+// loading. Behavior trees are rebuilt from the species and get their node
+// states back, in-flight GOAP plans are planned again.
+type Save struct {
+	Version         int                    `json:"version"`
+	Tick            uint                   `json:"tick"`
+	NextID          int                    `json:"next_id"`
+	Width           float64                `json:"width"`
+	Height          float64                `json:"height"`
+	NavigationGrid  [][]bool               `json:"navigation_grid"`
+	Terrain         [][]float64            `json:"terrain"`
+	StaticObstacles common.StaticObstacles `json:"static_obstacles"`
+	Config          Config                 `json:"config"`
+	Weather         WeatherState           `json:"weather"`
+	RNG             []byte                 `json:"rng"` // State of the world's random source
+	Entities        []SavedEntity          `json:"entities"`
+}
+
+// SavedEntity holds an entity's components
+type SavedEntity struct {
+	ID         int           `json:"id"`
+	Body       Body          `json:"body"`
+	Transform  Transform     `json:"transform"`
+	Motion     *Motion       `json:"motion,omitempty"`
+	Path       *SavedPath    `json:"path,omitempty"`
+	Stats      *common.Stats `json:"stats,omitempty"`
+	Perception *Perception   `json:"perception,omitempty"`
+	Brain      *SavedBrain   `json:"brain,omitempty"`
+}
+
+// SavedPath is the path an entity was following. Entities keep following
+// it after loading rather than searching again from where they stand,
+// which could pick another route.
+type SavedPath struct {
+	Waypoints []common.Vector2D `json:"waypoints"`
+	Goal      cell              `json:"goal"`
+}
+
+// SavedBrain holds what a brain remembers between ticks
+type SavedBrain struct {
+	NodeStates []int           `json:"node_states"`
+	Memory     json.RawMessage `json:"memory,omitempty"` // Species memory, see memorizer
+	Traced     bool            `json:"traced,omitempty"`
+}
+
+// memorizer is implemented by minds that remember more than their components
+type memorizer interface {
+	saveMemory() (json.RawMessage, error)
+	loadMemory(data json.RawMessage) error
+}
+
+// Save captures the world. Like map edits it must be called between ticks.
+func (w *World) Save() (*Save, error) {
+	rng, err := w.pcg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	s := &Save{
+		Version:         saveVersion,
+		Tick:            w.tick,
+		NextID:          w.nextID,
+		Width:           w.Width,
+		Height:          w.Height,
+		NavigationGrid:  cloneGrid(w.NavigationGrid),
+		Terrain:         cloneGrid(w.Terrain),
+		StaticObstacles: cloneObstacles(w.StaticObstacles),
+		Config:          w.Config.clone(),
+		Weather:         w.weather,
+		RNG:             rng,
+		Entities:        make([]SavedEntity, 0, w.Bodies.Len()),
+	}
+	for id, body := range w.Bodies.All() {
+		e := SavedEntity{ID: id, Body: *body, Transform: *w.Transforms.Get(id)}
+		if m := w.Motions.Get(id); m != nil {
+			saved := Motion{Gait: m.Gait, Speed: m.Speed, Movement: m.Movement}
+			if m.TargetPos != nil {
+				target := *m.TargetPos
+				saved.TargetPos = &target
+			}
+			e.Motion = &saved
+			if m.pathValid {
+				e.Path = &SavedPath{Waypoints: slices.Clone(m.path), Goal: m.pathGoal}
+			}
+		}
+		if stats := w.Stats.Get(id); stats != nil {
+			saved := *stats
+			e.Stats = &saved
+		}
+		if p := w.Perceptions.Get(id); p != nil {
+			saved := *p
+			saved.VisibleEntities = slices.Clone(p.VisibleEntities)
+			saved.VisibleResources = slices.Clone(p.VisibleResources)
+			e.Perception = &saved
+		}
+		if b := w.Brains.Get(id); b != nil {
+			brain := &SavedBrain{NodeStates: append([]int(nil), b.states...), Traced: b.Trace != nil}
+			if m, ok := b.Mind.(memorizer); ok {
+				if brain.Memory, err = m.saveMemory(); err != nil {
+					return nil, fmt.Errorf("save entity %d: %w", id, err)
+				}
+			}
+			e.Brain = brain
+		}
+		s.Entities = append(s.Entities, e)
+	}
+	return s, nil
+}
+
+// Load replaces the whole world with a save, keeping the world's ID and
+// event subscribers. It must be called between ticks. Observers see a new
+// map version, and entities that are in the save get no spawned events.
+func (w *World) Load(s *Save) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	pcg := &rand.PCG{}
+	if err := pcg.UnmarshalBinary(s.RNG); err != nil {
+		return fmt.Errorf("%w: rng: %v", ErrBadSave, err)
+	}
+
+	// Build the entities first so a bad one leaves the world untouched
+	var stores struct {
+		bodies      ecs.Store[Body]
+		transforms  ecs.Store[Transform]
+		motions     ecs.Store[Motion]
+		stats       ecs.Store[common.Stats]
+		perceptions ecs.Store[Perception]
+		brains      ecs.Store[Brain]
+	}
+	for _, e := range s.Entities {
+		stores.bodies.Add(e.ID, e.Body)
+		stores.transforms.Add(e.ID, e.Transform)
+		if e.Motion != nil {
+			m := *e.Motion
+			if m.TargetPos != nil {
+				target := *m.TargetPos
+				m.TargetPos = &target // The save may be loaded again
+			}
+			if e.Path != nil {
+				m.path, m.pathGoal, m.pathValid = slices.Clone(e.Path.Waypoints), e.Path.Goal, true
+			}
+			stores.motions.Add(e.ID, m)
+		}
+		if e.Stats != nil {
+			stores.stats.Add(e.ID, *e.Stats)
+		}
+		if e.Perception != nil {
+			stores.perceptions.Add(e.ID, *e.Perception)
+		}
+		if e.Brain != nil {
+			brain, err := e.Brain.restore(e.Body.Type)
+			if err != nil {
+				return fmt.Errorf("%w: entity %d: %v", ErrBadSave, e.ID, err)
+			}
+			stores.brains.Add(e.ID, brain)
+		}
+	}
+
+	w.tick, w.nextID = s.Tick, s.NextID
+	w.Width, w.Height = s.Width, s.Height
+	w.NavigationGrid = cloneGrid(s.NavigationGrid)
+	w.Terrain = cloneGrid(s.Terrain)
+	w.StaticObstacles = cloneObstacles(s.StaticObstacles)
+	w.Config = s.Config.clone()
+	w.weather = s.Weather
+	w.pcg, w.rng = pcg, rand.New(pcg)
+	w.Bodies, w.Transforms, w.Motions = stores.bodies, stores.transforms, stores.motions
+	w.Stats, w.Perceptions, w.Brains = stores.stats, stores.perceptions, stores.brains
+	w.view = worldView{}
+
+	w.opaque = make([][]bool, len(w.NavigationGrid))
+	for y, row := range w.NavigationGrid {
+		w.opaque[y] = make([]bool, len(row))
+	}
+	for _, o := range w.StaticObstacles.Walls {
+		for _, c := range obstacleCells(o) {
+			if w.inBounds(c.X, c.Y) {
+				w.opaque[c.Y][c.X] = true
+			}
+		}
+	}
+	w.mapVersion++
+	w.labelRegions()
+	w.buildHierarchy()
+	w.buildFlowFields()
+	w.Publish()
+	return nil
+}
+
+// validate checks what Load relies on
+func (s *Save) validate() error {
+	if s.Version != saveVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrBadSave, s.Version, saveVersion)
+	}
+	height, width := int(s.Height), int(s.Width)
+	if width <= 0 || height <= 0 || len(s.NavigationGrid) != height || len(s.Terrain) != height {
+		return fmt.Errorf("%w: grid does not match the %gx%g map", ErrBadSave, s.Width, s.Height)
+	}
+	for y := range height {
+		if len(s.NavigationGrid[y]) != width || len(s.Terrain[y]) != width {
+			return fmt.Errorf("%w: row %d does not match the map width", ErrBadSave, y)
+		}
+	}
+	if s.Config.TPS <= 0 || s.Config.TPS > MaxTPS || s.Config.TicksPerDay <= 0 {
+		return fmt.Errorf("%w: tps must be between 1 and %d and ticks_per_day positive", ErrBadSave, MaxTPS)
+	}
+	for _, list := range [][]common.StaticObstacle{
+		s.StaticObstacles.Walls, s.StaticObstacles.WaterSources,
+		s.StaticObstacles.FoodSources, s.StaticObstacles.RestAreas,
+	} {
+		for _, o := range list {
+			if err := s.validateObstacle(o); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range s.Entities {
+		if e.ID <= 0 || e.ID > s.NextID {
+			return fmt.Errorf("%w: entity id %d outside 1..%d", ErrBadSave, e.ID, s.NextID)
+		}
+	}
+	return nil
+}
+
+// validateObstacle checks that an obstacle covers cells and only cells of
+// the map, which map edits index without checking
+func (s *Save) validateObstacle(o common.StaticObstacle) error {
+	x, y := cellOf(o.Position)
+	if x < 0 || y < 0 || x >= int(s.Width) || y >= int(s.Height) {
+		return fmt.Errorf("%w: %s at (%g, %g) is off the map", ErrBadSave, o.Type, o.Position.X, o.Position.Y)
+	}
+	if len(o.Shape) > 0 {
+		// Bound the outline before walking its cells, a cell of margin
+		// allows outlines that stick out without covering outside cells
+		minX, minY, maxX, maxY := shapeBounds(o.Shape)
+		if !(minX >= -1 && minY >= -1 && maxX <= s.Width+1 && maxY <= s.Height+1) {
+			return fmt.Errorf("%w: %s shape at (%g, %g) reaches off the map", ErrBadSave, o.Type, o.Position.X, o.Position.Y)
+		}
+	}
+	cells := obstacleCells(o)
+	if len(cells) == 0 {
+		return fmt.Errorf("%w: %s at (%g, %g) covers no cell", ErrBadSave, o.Type, o.Position.X, o.Position.Y)
+	}
+	for _, c := range cells {
+		if c.X < 0 || c.Y < 0 || c.X >= int(s.Width) || c.Y >= int(s.Height) {
+			return fmt.Errorf("%w: %s covers (%d, %d) off the map", ErrBadSave, o.Type, c.X, c.Y)
+		}
+	}
+	return nil
+}
+
+// restore rebuilds a brain for the species and gives it back its memory
+func (sb *SavedBrain) restore(t common.EntityType) (Brain, error) {
+	tree, ok := NewBehaviorTree(t)
+	newMind, hasMind := minds[t]
+	if !ok || !hasMind {
+		return Brain{}, fmt.Errorf("no behavior tree for entity type %q", t)
+	}
+	brain := NewBrain(tree, newMind())
+	if len(sb.NodeStates) != len(brain.states) {
+		return Brain{}, fmt.Errorf("%d node states for a tree of %d nodes", len(sb.NodeStates), len(brain.states))
+	}
+	if err := btree.CheckStates(tree, sb.NodeStates); err != nil {
+		return Brain{}, err
+	}
+	copy(brain.states, sb.NodeStates)
+	if m, ok := brain.Mind.(memorizer); ok && len(sb.Memory) > 0 {
+		if err := m.loadMemory(sb.Memory); err != nil {
+			return Brain{}, err
+		}
+	}
+	if sb.Traced {
+		brain.Trace = btree.NewTrace()
+	}
+	return brain, nil
+}
+
+// cloneObstacles copies the obstacle lists. Shapes are never modified in
+// place, so copies share them.
+func cloneObstacles(o common.StaticObstacles) common.StaticObstacles {
+	return common.StaticObstacles{
+		Walls:        slices.Clone(o.Walls),
+		WaterSources: slices.Clone(o.WaterSources),
+		FoodSources:  slices.Clone(o.FoodSources),
+		RestAreas:    slices.Clone(o.RestAreas),
+	}
+}
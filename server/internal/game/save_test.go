@@ -0,0 +1,82 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// A loaded world picks up exactly where the saved one left off
+func TestSaveLoad(t *testing.T) {
+	w := newTestWorld(t)
+	for range 200 {
+		w.Tick()
+	}
+	save, err := w.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(save)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 300 {
+		w.Tick()
+	}
+
+	var decoded Save
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewWorld(20, 20)
+	if err := loaded.Load(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(loaded.Snapshot().Entities); got != len(save.Entities) {
+		t.Fatalf("loaded snapshot has %d entities, saved %d", got, len(save.Entities))
+	}
+	for range 300 {
+		loaded.Tick()
+	}
+
+	want, _ := json.Marshal(w.Snapshot().Entities)
+	got, _ := json.Marshal(loaded.Snapshot().Entities)
+	if !bytes.Equal(got, want) {
+		t.Errorf("loaded world diverged after 300 ticks")
+	}
+
+	decoded.Version++
+	if err := loaded.Load(&decoded); err == nil {
+		t.Error("loaded a save of another version")
+	}
+	decoded.Version--
+
+	bad := decoded
+	bad.Config.TPS = MaxTPS + 1
+	if err := loaded.Load(&bad); !errors.Is(err, ErrBadSave) {
+		t.Errorf("loading tps %d: %v", bad.Config.TPS, err)
+	}
+	bad = decoded
+	bad.StaticObstacles.Walls = append(slices.Clone(bad.StaticObstacles.Walls),
+		common.StaticObstacle{Type: common.ObstacleTypeWall, Position: common.Vector2D{X: 3, Y: 3}, Shape: RectShape(3, 3, 45, 4)})
+	if err := loaded.Load(&bad); !errors.Is(err, ErrBadSave) {
+		t.Errorf("loading a wall reaching off the map: %v", err)
+	}
+	i := slices.IndexFunc(decoded.Entities, func(e SavedEntity) bool { return e.Brain != nil })
+	for _, state := range []int{99, -1} {
+		bad = decoded
+		bad.Entities = slices.Clone(decoded.Entities)
+		brain := *bad.Entities[i].Brain
+		brain.NodeStates = slices.Clone(brain.NodeStates)
+		brain.NodeStates[1] = state
+		bad.Entities[i].Brain = &brain
+		if err := loaded.Load(&bad); !errors.Is(err, ErrBadSave) {
+			t.Errorf("loading node state %d: %v", state, err)
+		}
+	}
+	loaded.Tick()
+}
@@ -0,0 +1,142 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Obstacles with a Shape cover every cell whose center lies inside the
+// outline. Obstacles without a Shape cover the single cell at their Position.
+
+// RectShape outlines the cells from (x0, y0) to (x1, y1) inclusive
+func RectShape(x0, y0, x1, y1 int) []common.Vector2D {
+	minX, maxX := float64(min(x0, x1)), float64(max(x0, x1)+1)
+	minY, maxY := float64(min(y0, y1)), float64(max(y0, y1)+1)
+	return []common.Vector2D{{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY}}
+}
+
+// blobShape returns an irregular outline of n points around center, each
+// between 0.6 and 1 times radius away
+func blobShape(center common.Vector2D, radius float64, n int, rng *rand.Rand) []common.Vector2D {
+	shape := make([]common.Vector2D, n)
+	for i := range shape {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		r := radius * (0.6 + 0.4*rng.Float64())
+		shape[i] = common.Vector2D{X: center.X + r*math.Cos(angle), Y: center.Y + r*math.Sin(angle)}
+	}
+	return shape
+}
+
+// newShapedObstacle returns a wall, water or food source covering shape.
+// Resources hold as much as one single cell source per covered cell.
+func newShapedObstacle(t common.ObstacleType, shape []common.Vector2D) (common.StaticObstacle, error) {
+	o, err := newObstacle(t, 0, 0)
+	if err != nil {
+		return o, err
+	}
+	o.Shape = shape
+	cells := obstacleCells(o)
+	if len(cells) == 0 {
+		return o, fmt.Errorf("add %s: shape covers no cell", t)
+	}
+	o.Amount *= float64(len(cells))
+	o.Capacity *= float64(len(cells))
+
+	// Position is the covered cell nearest the middle of the shape
+	minX, minY, maxX, maxY := shapeBounds(shape)
+	middle := common.Vector2D{X: (minX + maxX) / 2, Y: (minY + maxY) / 2}
+	best := math.Inf(1)
+	for _, c := range cells {
+		center := common.Vector2D{X: float64(c.X) + 0.5, Y: float64(c.Y) + 0.5}
+		if d := center.Distance(middle); d < best {
+			o.Position, best = common.Vector2D{X: float64(c.X), Y: float64(c.Y)}, d
+		}
+	}
+	return o, nil
+}
+
+// obstacleCells returns the cells an obstacle covers
+func obstacleCells(o common.StaticObstacle) []cell {
+	if len(o.Shape) == 0 {
+		x, y := cellOf(o.Position)
+		return []cell{{x, y}}
+	}
+	minX, minY, maxX, maxY := shapeBounds(o.Shape)
+	var cells []cell
+	for y := int(math.Floor(minY)); y <= int(math.Floor(maxY)); y++ {
+		for x := int(math.Floor(minX)); x <= int(math.Floor(maxX)); x++ {
+			if covers(o, x, y) {
+				cells = append(cells, cell{x, y})
+			}
+		}
+	}
+	return cells
+}
+
+// covers reports whether the obstacle covers cell (x, y)
+func covers(o common.StaticObstacle, x, y int) bool {
+	if len(o.Shape) == 0 {
+		ox, oy := cellOf(o.Position)
+		return ox == x && oy == y
+	}
+	return insideShape(o.Shape, common.Vector2D{X: float64(x) + 0.5, Y: float64(y) + 0.5})
+}
+
+// closestPoint returns the point of the obstacle nearest to pos and the
+// distance to it. That is the edge of a shaped obstacle, or pos itself when
+// inside it. A plain obstacle fills its cell, so its position is returned
+// with the distance to the cell's nearest edge.
+func closestPoint(o common.StaticObstacle, pos common.Vector2D) (common.Vector2D, float64) {
+	if len(o.Shape) == 0 {
+		dx := math.Max(0, math.Max(o.Position.X-pos.X, pos.X-o.Position.X-1))
+		dy := math.Max(0, math.Max(o.Position.Y-pos.Y, pos.Y-o.Position.Y-1))
+		return o.Position, math.Hypot(dx, dy)
+	}
+	if insideShape(o.Shape, pos) {
+		return pos, 0
+	}
+	best, bestDist := o.Shape[0], math.Inf(1)
+	for i, a := range o.Shape {
+		b := o.Shape[(i+1)%len(o.Shape)]
+		p := closestOnSegment(a, b, pos)
+		if d := pos.Distance(p); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best, bestDist
+}
+
+func closestOnSegment(a, b, p common.Vector2D) common.Vector2D {
+	ab := b.Subtract(a)
+	lengthSq := ab.X*ab.X + ab.Y*ab.Y
+	if lengthSq == 0 {
+		return a
+	}
+	t := ((p.X-a.X)*ab.X + (p.Y-a.Y)*ab.Y) / lengthSq
+	return a.Add(ab.Scale(math.Max(0, math.Min(1, t))))
+}
+
+// insideShape tests p against the outline with the even-odd rule
+func insideShape(shape []common.Vector2D, p common.Vector2D) bool {
+	inside := false
+	for i, a := range shape {
+		b := shape[(i+1)%len(shape)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func shapeBounds(shape []common.Vector2D) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range shape {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	return minX, minY, maxX, maxY
+}
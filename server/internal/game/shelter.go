@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+const (
+	restAreaRadius   = 2.5
+	restAreaCapacity = 4
+
+	// shelterRecovery multiplies tiredness recovery while resting in shelter
+	shelterRecovery = 2
+)
+
+// shelteredTypes are the species rest areas protect from predators
+var shelteredTypes = map[common.EntityType]bool{
+	common.EntityTypeGoat: true,
+}
+
+// NewRestArea returns a shelter zone centered on pos
+func NewRestArea(pos common.Vector2D, radius float64, capacity int) common.StaticObstacle {
+	return common.StaticObstacle{
+		Type:     common.ObstacleTypeRestArea,
+		Position: pos,
+		Radius:   radius,
+		Capacity: float64(capacity),
+	}
+}
+
+// restAreaAt returns the index of the rest area containing pos, or -1
+func (w *World) restAreaAt(pos common.Vector2D) int {
+	for i, a := range w.StaticObstacles.RestAreas {
+		if pos.Distance(a.Position) <= a.Radius {
+			return i
+		}
+	}
+	return -1
+}
+
+// NearestFreeRestArea returns the closest rest area that still has room and
+// its distance, infinite when every area is full or there is none
+func (w *World) NearestFreeRestArea(pos common.Vector2D) (common.Vector2D, float64) {
+	nearest, best := common.Vector2D{}, math.Inf(1)
+	for _, a := range w.StaticObstacles.RestAreas {
+		if float64(a.Occupants) >= a.Capacity {
+			continue
+		}
+		if d := pos.Distance(a.Position); d < best {
+			nearest, best = a.Position, d
+		}
+	}
+	return nearest, best
+}
+
+// Sheltered reports whether the agent started the tick protected in a rest area
+func (a *Agent) Sheltered(world *World) bool {
+	v := world.View(a.ID)
+	return v != nil && v.Sheltered
+}
+
+// shelterSystem counts who is inside each rest area at the start of the
+// tick. Animals of a sheltered species are protected in ID order until the
+// area is full, latecomers can rest there but predators still see them.
+func (w *World) shelterSystem() {
+	areas := w.StaticObstacles.RestAreas
+	for i := range areas {
+		areas[i].Occupants = 0
+	}
+	for i := range w.view.entities {
+		v := &w.view.entities[i]
+		if !shelteredTypes[v.Type] {
+			continue
+		}
+		a := w.restAreaAt(v.Position)
+		if a < 0 || float64(areas[a].Occupants) >= areas[a].Capacity {
+			continue
+		}
+		areas[a].Occupants++
+		v.Sheltered = true
+	}
+}
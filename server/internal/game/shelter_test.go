@@ -0,0 +1,41 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// Goats past a rest area's capacity rest there unprotected, in ID order
+func TestShelterFullRestArea(t *testing.T) {
+	w := newEmptyWorld(t, 20)
+	w.StaticObstacles.RestAreas = nil
+	center := common.Vector2D{X: 10, Y: 10}
+	if err := w.AddRestArea(center, 2.5, 2); err != nil {
+		t.Fatal(err)
+	}
+	goats := []int{
+		w.SpawnGoat(common.Vector2D{X: 10, Y: 10}),
+		w.SpawnGoat(common.Vector2D{X: 11, Y: 10}),
+		w.SpawnGoat(common.Vector2D{X: 10, Y: 11}),
+	}
+	wolf := w.SpawnWolf(common.Vector2D{X: 9, Y: 10})
+
+	w.captureView()
+	w.shelterSystem()
+	for i, id := range goats {
+		if got, want := w.View(id).Sheltered, i < 2; got != want {
+			t.Errorf("goat %d sheltered %v, want %v", i, got, want)
+		}
+	}
+	if w.View(wolf).Sheltered {
+		t.Error("wolf sheltered")
+	}
+	if occupants := w.StaticObstacles.RestAreas[0].Occupants; occupants != 2 {
+		t.Errorf("%d occupants, want 2", occupants)
+	}
+	if _, dist := w.NearestFreeRestArea(common.Vector2D{X: 2, Y: 2}); !math.IsInf(dist, 1) {
+		t.Errorf("full rest area offered at distance %g", dist)
+	}
+}
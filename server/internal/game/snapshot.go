@@ -0,0 +1,285 @@
+package game
+
+import (
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game/btree"
+	"github.com/xSaCh/animalia/internal/game/goap"
+)
+
+// EntityView is a read-only copy of an entity's body, transform and stats
+// taken at the start of a tick. While entities tick in parallel they only
+// read each other through views, never through the live components.
+type EntityView struct {
+	ID        int                `json:"id"`
+	Type      common.EntityType  `json:"type"`
+	Position  common.Vector2D    `json:"position"`
+	Direction common.Vector2D    `json:"direction"`
+	State     common.EntityState `json:"state"`
+	Stats     common.Stats       `json:"stats"`
+	Radius    float64            `json:"radius"`
+	Sheltered bool               `json:"sheltered"` // Protected in a rest area, see shelterSystem
+}
+
+// worldView holds the views of every entity, sorted by ID
+type worldView struct {
+	entities []EntityView
+	index    map[int]int
+	grid     spatialIndex // Over entities, for perception
+}
+
+// captureView snapshots every entity for the coming read phase
+func (w *World) captureView() {
+	w.view.entities = w.view.entities[:0]
+	for id, body := range w.Bodies.All() {
+		v := EntityView{
+			ID:     id,
+			Type:   body.Type,
+			State:  body.State,
+			Radius: body.Radius,
+		}
+		if t := w.Transforms.Get(id); t != nil {
+			v.Position, v.Direction = t.Position, t.Direction
+		}
+		if stats := w.Stats.Get(id); stats != nil {
+			v.Stats = *stats
+		}
+		w.view.entities = append(w.view.entities, v)
+	}
+	if w.view.index == nil {
+		w.view.index = make(map[int]int, len(w.view.entities))
+	}
+	clear(w.view.index)
+	for i, v := range w.view.entities {
+		w.view.index[v.ID] = i
+	}
+	w.view.grid = newSpatialIndex(w.Width, w.Height, spatialBucket, len(w.view.entities), w.viewPosition)
+}
+
+// viewPosition returns the position of the i-th view
+func (w *World) viewPosition(i int) common.Vector2D {
+	return w.view.entities[i].Position
+}
+
+// View returns an entity as it was at the start of the current tick. The
+// pointer is only valid until the next tick.
+func (w *World) View(id int) *EntityView {
+	i, ok := w.view.index[id]
+	if !ok {
+		return nil
+	}
+	return &w.view.entities[i]
+}
+
+// Views returns every entity as it was at the start of the current tick
+func (w *World) Views() []EntityView {
+	return w.view.entities
+}
+
+// Snapshot is an immutable copy of the world published at the end of every
+// tick. Observers such as transports and recorders read it from any
+// goroutine without locking the tick loop. It encodes to the same JSON as
+// World, with the tick added.
+type Snapshot struct {
+	Tick    uint         `json:"tick"`
+	Clock   Clock        `json:"clock"`
+	Weather WeatherState `json:"weather"`
+	ID      int          `json:"id"`
+	Width   float64      `json:"width"`
+	Height  float64      `json:"height"`
+	*MapSnapshot
+	StaticObstacles common.StaticObstacles `json:"static_obstacles"` // Copied every tick, food and water levels change
+	Entities        []EntitySnapshot       `json:"entities"`
+	Events          []Event                `json:"events"` // Everything that happened during the tick
+	Config          Config                 `json:"config"`
+
+	index spatialIndex // Over Entities, see EntitiesIn
+}
+
+// EntitySnapshot is the public state of one entity. Components the entity
+// lacks are left zero.
+type EntitySnapshot struct {
+	ID int `json:"id"`
+	Body
+	Transform
+	Motion
+	Stats      common.Stats `json:"stats"`
+	Perception Perception   `json:"perception"`
+	Sheltered  bool         `json:"sheltered"`
+}
+
+// MapSnapshot holds the parts of the world that rarely change. It is only
+// copied again when the map changes, otherwise snapshots share it.
+type MapSnapshot struct {
+	NavigationGrid [][]bool    `json:"navigation_grid,omitempty"` // Left out by streams, they send chunks
+	Terrain        [][]float64 `json:"terrain,omitempty"`
+	Version        uint        `json:"map_version"` // Changes whenever the map is edited
+
+	chunks mapChunks
+}
+
+// Snapshot returns the state published at the end of the last tick. It is
+// safe to call concurrently with Tick and must not be modified.
+func (w *World) Snapshot() *Snapshot {
+	return w.snapshot.Load()
+}
+
+// Publish republishes the snapshot after changes made between ticks, such
+// as spawns or map edits, so observers see them without waiting for the next
+// tick. Their events still arrive with the next tick.
+func (w *World) Publish() {
+	events := w.events
+	w.events = nil
+	w.publishSnapshot()
+	w.events = events
+}
+
+// publishSnapshot copies the world for observers
+func (w *World) publishSnapshot() {
+	m := w.mapSnapshot
+	if m == nil || m.Version != w.mapVersion {
+		m = &MapSnapshot{
+			NavigationGrid: cloneGrid(w.NavigationGrid),
+			Terrain:        cloneGrid(w.Terrain),
+			Version:        w.mapVersion,
+		}
+		m.chunks = newMapChunks(m, w.mapSnapshot)
+		w.mapSnapshot = m
+	}
+
+	entities := make([]EntitySnapshot, 0, w.Bodies.Len())
+	for id, body := range w.Bodies.All() {
+		entities = append(entities, w.entitySnapshot(id, body))
+	}
+
+	events := w.events
+	if events == nil {
+		events = []Event{} // Encode as an empty list rather than null
+	}
+
+	w.snapshot.Store(&Snapshot{
+		Tick:            w.tick,
+		Clock:           w.Clock(),
+		Weather:         w.weather,
+		ID:              w.ID,
+		Width:           w.Width,
+		Height:          w.Height,
+		MapSnapshot:     m,
+		StaticObstacles: cloneObstacles(w.StaticObstacles),
+		Entities:        entities,
+		Events:          events,
+		Config:          w.Config.clone(),
+		index:           newSpatialIndex(w.Width, w.Height, spatialBucket, len(entities), func(i int) common.Vector2D { return entities[i].Position }),
+	})
+}
+
+// Entity returns the public state of one entity as it is now, false when
+// it doesn't exist. It must be called between ticks.
+func (w *World) Entity(id int) (EntitySnapshot, bool) {
+	body := w.Bodies.Get(id)
+	if body == nil {
+		return EntitySnapshot{}, false
+	}
+	return w.entitySnapshot(id, body), true
+}
+
+// BrainState describes an entity's behavior tree, node by node in
+// depth-first order
+type BrainState struct {
+	Traced bool        `json:"traced"` // Statuses and scores are only recorded while traced
+	Nodes  []NodeState `json:"nodes"`
+}
+
+// NodeState is one behavior tree node and what it currently remembers
+type NodeState struct {
+	ID     int      `json:"id"`
+	Label  string   `json:"label"`
+	Depth  int      `json:"depth"`
+	State  int      `json:"state"`            // The node's own memory, such as its running child
+	Status string   `json:"status,omitempty"` // Returned on the last tick, when traced
+	Score  *float64 `json:"score,omitempty"`  // Utility score on the last tick, when traced
+	Goal   string   `json:"goal,omitempty"`   // GOAP goal being pursued
+	Plan   []string `json:"plan,omitempty"`   // Remaining GOAP actions
+}
+
+// BrainState returns the state of an entity's behavior tree, false when it
+// has none. It must be called between ticks.
+func (w *World) BrainState(id int) (*BrainState, bool) {
+	b := w.Brains.Get(id)
+	if b == nil {
+		return nil, false
+	}
+	ctx := &btree.TickContext{NodeStates: b.states}
+	state := &BrainState{Traced: b.Trace != nil, Nodes: []NodeState{}}
+	btree.Walk(b.Tree, func(n btree.Node, depth int) {
+		ns := NodeState{ID: n.ID(), Label: btree.Label(n), Depth: depth, State: b.states[n.ID()]}
+		if b.Trace != nil {
+			if status, ok := b.Trace.Statuses[n.ID()]; ok {
+				ns.Status = status.String()
+			}
+			if score, ok := b.Trace.Scores[n.ID()]; ok {
+				ns.Score = &score
+			}
+		}
+		if p, ok := n.(*goap.PlannerNode); ok && p.Goal() != nil {
+			ns.Goal = p.Goal().Name
+			for _, a := range p.Plan(ctx) {
+				ns.Plan = append(ns.Plan, a.Name)
+			}
+		}
+		state.Nodes = append(state.Nodes, ns)
+	})
+	return state, true
+}
+
+// TraceEntity starts or stops recording the statuses of an entity's
+// behavior tree, false when it has none. It must be called between ticks.
+func (w *World) TraceEntity(id int, on bool) bool {
+	b := w.Brains.Get(id)
+	if b == nil {
+		return false
+	}
+	if on {
+		b.EnableTrace()
+	} else {
+		b.Trace = nil
+	}
+	return true
+}
+
+// entitySnapshot copies the entity's public components, leaving out its
+// brain and path
+func (w *World) entitySnapshot(id int, body *Body) EntitySnapshot {
+	e := EntitySnapshot{ID: id, Body: *body}
+	if t := w.Transforms.Get(id); t != nil {
+		e.Transform = *t
+	}
+	if m := w.Motions.Get(id); m != nil {
+		e.Motion = Motion{Gait: m.Gait, Speed: m.Speed, Movement: m.Movement}
+		if m.TargetPos != nil {
+			target := *m.TargetPos
+			e.TargetPos = &target
+		}
+	}
+	if stats := w.Stats.Get(id); stats != nil {
+		e.Stats = *stats
+	}
+	if p := w.Perceptions.Get(id); p != nil {
+		e.Perception = *p
+		e.Perception.VisibleEntities = slices.Clone(p.VisibleEntities)
+		e.Perception.VisibleResources = slices.Clone(p.VisibleResources)
+	}
+	if v := w.View(id); v != nil {
+		e.Sheltered = v.Sheltered
+	}
+	return e
+}
+
+func cloneGrid[T any](grid [][]T) [][]T {
+	c := make([][]T, len(grid))
+	for i, row := range grid {
+		c[i] = slices.Clone(row)
+	}
+	return c
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func newTestWorld(t *testing.T) *World {
+	t.Helper()
+	cfg := DefaultConfig(20)
+	cfg.Seed = 7
+	w := NewWorldWithConfig(40, cfg)
+	for i := range 33 {
+		pos, err := w.GetRandomWalkablePosition()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 30 {
+			w.SpawnGoat(pos)
+		} else {
+			w.SpawnWolf(pos)
+		}
+	}
+	return w
+}
+
+// Run with -race: readers encode snapshots while the world keeps ticking
+func TestSnapshotConcurrentReads(t *testing.T) {
+	w := newTestWorld(t)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := w.Snapshot()
+				if s.Tick < last {
+					t.Errorf("snapshot went back from tick %d to %d", last, s.Tick)
+					return
+				}
+				last = s.Tick
+				if _, err := json.Marshal(s); err != nil {
+					t.Errorf("encode snapshot: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for range 300 {
+		w.Tick()
+	}
+	close(done)
+	wg.Wait()
+
+	if got := w.Snapshot().Tick; got != 300 {
+		t.Errorf("last snapshot tick = %d, want 300", got)
+	}
+}
+
+func TestSnapshotIsImmutable(t *testing.T) {
+	w := newTestWorld(t)
+	w.Tick()
+	s := w.Snapshot()
+	before, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 100 {
+		w.Tick()
+	}
+	after, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("snapshot changed after later ticks")
+	}
+	if w.Snapshot() == s {
+		t.Error("ticking did not publish a new snapshot")
+	}
+}
+
+func TestMapChunks(t *testing.T) {
+	w := newTestWorld(t)
+	w.Tick()
+	before := w.Snapshot().MapSnapshot
+
+	// Painting inside chunk (1, 1) only changes that chunk
+	w.PaintWalkable(35, 35, 36, 36, false)
+	w.Publish()
+	m := w.Snapshot().MapSnapshot
+	for _, id := range m.Chunks() {
+		old, _ := before.ChunkVersion(id)
+		version, _ := m.ChunkVersion(id)
+		if changed := id == (ChunkID{1, 1}); (version != old) != changed || (changed && version != m.Version) {
+			t.Errorf("chunk %+v went from version %d to %d at map version %d", id, old, version, m.Version)
+		}
+	}
+
+	// Decoding the runs gives back the grid
+	c, _ := m.Chunk(ChunkID{1, 1})
+	if c.Width != 8 || c.Height != 8 {
+		t.Fatalf("edge chunk is %dx%d, want 8x8", c.Width, c.Height)
+	}
+	walkable, i := true, 0
+	for _, run := range c.Walkable {
+		for range run {
+			x, y := c.X*ChunkSize+i%c.Width, c.Y*ChunkSize+i/c.Width
+			if m.NavigationGrid[y][x] != walkable {
+				t.Fatalf("cell (%d, %d) decoded as walkable %t", x, y, walkable)
+			}
+			i++
+		}
+		walkable = !walkable
+	}
+	if i != c.Width*c.Height {
+		t.Fatalf("runs cover %d cells, want %d", i, c.Width*c.Height)
+	}
+}
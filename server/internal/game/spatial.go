@@ -0,0 +1,115 @@
+package game
+
+import (
+	"math"
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// spatialBucket is the side of a snapshot's spatial index bucket in cells.
+// Areas clients look at are tens of cells across, so a query touches a
+// handful of buckets.
+const spatialBucket = 16
+
+// Area is an axis aligned rectangle of the map, Min inclusive and Max
+// exclusive
+type Area struct {
+	Min common.Vector2D `json:"min"`
+	Max common.Vector2D `json:"max"`
+}
+
+// AreaAround returns the square of the given half size centered on pos
+func AreaAround(pos common.Vector2D, radius float64) Area {
+	return Area{
+		Min: common.Vector2D{X: pos.X - radius, Y: pos.Y - radius},
+		Max: common.Vector2D{X: pos.X + radius, Y: pos.Y + radius},
+	}
+}
+
+// Contains reports whether pos lies inside the area
+func (a Area) Contains(pos common.Vector2D) bool {
+	return pos.X >= a.Min.X && pos.X < a.Max.X && pos.Y >= a.Min.Y && pos.Y < a.Max.Y
+}
+
+// Grow returns the area extended by margin on every side
+func (a Area) Grow(margin float64) Area {
+	return Area{
+		Min: common.Vector2D{X: a.Min.X - margin, Y: a.Min.Y - margin},
+		Max: common.Vector2D{X: a.Max.X + margin, Y: a.Max.Y + margin},
+	}
+}
+
+// spatialIndex buckets positions, such as those of a snapshot's entities,
+// so area queries don't scan every one. Positions off the map fall in the
+// edge buckets.
+type spatialIndex struct {
+	size       float64 // Side of a bucket
+	cols, rows int
+	buckets    [][]int // Indexes of the positions, ascending
+}
+
+// newSpatialIndex buckets the n positions returned by position(0..n-1) over
+// a width x height map
+func newSpatialIndex(width, height, size float64, n int, position func(i int) common.Vector2D) spatialIndex {
+	idx := spatialIndex{
+		size: size,
+		cols: max(1, int(math.Ceil(width/size))),
+		rows: max(1, int(math.Ceil(height/size))),
+	}
+	idx.buckets = make([][]int, idx.cols*idx.rows)
+	for i := range n {
+		col, row := idx.bucketOf(position(i))
+		b := row*idx.cols + col
+		idx.buckets[b] = append(idx.buckets[b], i)
+	}
+	return idx
+}
+
+// bucketOf returns the column and row of the bucket holding pos
+func (idx *spatialIndex) bucketOf(pos common.Vector2D) (int, int) {
+	col := int(math.Floor(pos.X / idx.size))
+	row := int(math.Floor(pos.Y / idx.size))
+	return min(max(col, 0), idx.cols-1), min(max(row, 0), idx.rows-1)
+}
+
+// query returns the indexes of positions inside the area, ascending
+func (idx *spatialIndex) query(a Area, position func(i int) common.Vector2D) []int {
+	if len(idx.buckets) == 0 || a.Max.X <= a.Min.X || a.Max.Y <= a.Min.Y {
+		return nil
+	}
+	col0, row0 := idx.bucketOf(a.Min)
+	col1, row1 := idx.bucketOf(a.Max)
+	var found []int
+	for row := row0; row <= row1; row++ {
+		for col := col0; col <= col1; col++ {
+			for _, i := range idx.buckets[row*idx.cols+col] {
+				if a.Contains(position(i)) {
+					found = append(found, i)
+				}
+			}
+		}
+	}
+	slices.Sort(found)
+	return found
+}
+
+// EntitiesIn returns the entities inside the area in ID order. The
+// entities are shared with the snapshot and must not be modified.
+func (s *Snapshot) EntitiesIn(a Area) []EntitySnapshot {
+	found := s.index.query(a, func(i int) common.Vector2D { return s.Entities[i].Position })
+	entities := make([]EntitySnapshot, len(found))
+	for j, i := range found {
+		entities[j] = s.Entities[i]
+	}
+	return entities
+}
+
+// FindEntity returns an entity of the snapshot, false when it doesn't exist
+func (s *Snapshot) FindEntity(id int) (EntitySnapshot, bool) {
+	i, ok := slices.BinarySearchFunc(s.Entities, id, func(e EntitySnapshot, id int) int { return e.ID - id })
+	if !ok {
+		return EntitySnapshot{}, false
+	}
+	return s.Entities[i], true
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// SteeringWeights tunes how strongly each steering behavior pulls on an
+// entity's heading. Neighbors are the visible entities of the same type.
+type SteeringWeights struct {
+	Seek       float64 `json:"seek"`       // Toward the target, slowed down on arrival
+	Separation float64 `json:"separation"` // Away from neighbors that are too close
+	Alignment  float64 `json:"alignment"`  // Match the neighbors' heading
+	Cohesion   float64 `json:"cohesion"`   // Toward the neighbors' center
+	Avoidance  float64 `json:"avoidance"`  // Away from blocked cells ahead
+
+	NeighborRadius   float64 `json:"neighbor_radius"`
+	SeparationRadius float64 `json:"separation_radius"`
+	ArrivalRadius    float64 `json:"arrival_radius"` // Distance at which seek starts slowing down
+}
+
+// SteeringProfile holds a species' weights for normal movement and fleeing
+type SteeringProfile struct {
+	Normal  SteeringWeights `json:"normal"`
+	Fleeing SteeringWeights `json:"fleeing"`
+}
+
+// DefaultSteering returns the built-in steering profile of every species
+func DefaultSteering() map[common.EntityType]SteeringProfile {
+	return map[common.EntityType]SteeringProfile{
+		// Goats stick together as a herd and scatter when fleeing
+		common.EntityTypeGoat: {
+			Normal: SteeringWeights{
+				Seek: 1, Separation: 1.2, Alignment: 0.3, Cohesion: 0.4, Avoidance: 1.5,
+				NeighborRadius: 6, SeparationRadius: 1.5, ArrivalRadius: 2,
+			},
+			Fleeing: SteeringWeights{
+				Seek: 1.5, Separation: 2, Alignment: 0, Cohesion: 0, Avoidance: 1.5,
+				NeighborRadius: 6, SeparationRadius: 3, ArrivalRadius: 0,
+			},
+		},
+		// Wolves mostly go straight for their target
+		common.EntityTypeWolf: {
+			Normal: SteeringWeights{
+				Seek: 1, Separation: 0.5, Avoidance: 1.5,
+				NeighborRadius: 4, SeparationRadius: 1, ArrivalRadius: 1,
+			},
+			Fleeing: SteeringWeights{
+				Seek: 1, Separation: 0.5, Avoidance: 1.5,
+				NeighborRadius: 4, SeparationRadius: 1, ArrivalRadius: 0,
+			},
+		},
+	}
+}
+
+func (e *Agent) steeringWeights(world *World) SteeringWeights {
+	profile := world.Config.Steering[e.Type]
+	if e.State == common.EntityStateFleeing {
+		return profile.Fleeing
+	}
+	return profile.Normal
+}
+
+// steer combines the steering behaviors into a heading whose length is the
+// fraction of full speed to move at this tick (at most 1). seek is the next
+// waypoint, arrival slowing only applies when it is the final target.
+func (e *Agent) steer(world *World, seek common.Vector2D, final bool) common.Vector2D {
+	weights := e.steeringWeights(world)
+	toTarget := seek.Subtract(e.Position)
+	distance := toTarget.Length()
+
+	// Seek with arrival: full speed until inside the arrival radius
+	speed := 1.0
+	if final && weights.ArrivalRadius > 0 && distance < weights.ArrivalRadius {
+		speed = math.Max(distance/weights.ArrivalRadius, 0.5)
+	}
+	force := toTarget.Normalize().Scale(weights.Seek)
+
+	var separation, heading, center common.Vector2D
+	neighbors := 0
+	for _, id := range e.Perception.VisibleEntities {
+		o := world.View(id)
+		if o == nil || o.Type != e.Type {
+			continue
+		}
+		offset := e.Position.Subtract(o.Position)
+		dist := offset.Length()
+		if dist > weights.NeighborRadius {
+			continue
+		}
+		if dist < weights.SeparationRadius {
+			if dist == 0 {
+				// Stacked on the same spot, push apart by ID so both don't move the same way
+				offset, dist = common.Vector2D{X: float64(e.ID - o.ID), Y: 1}, 1
+			}
+			separation = separation.Add(offset.Normalize().Scale(1 / dist))
+		}
+		heading = heading.Add(o.Direction)
+		center = center.Add(o.Position)
+		neighbors++
+	}
+	force = force.Add(separation.Scale(weights.Separation))
+	if neighbors > 0 {
+		n := float64(neighbors)
+		force = force.Add(heading.Scale(1 / n).Normalize().Scale(weights.Alignment))
+		force = force.Add(center.Scale(1 / n).Subtract(e.Position).Normalize().Scale(weights.Cohesion))
+	}
+	force = force.Add(e.avoidObstacles(world, seek).Scale(weights.Avoidance))
+
+	return force.Normalize().Scale(speed)
+}
+
+// avoidObstacles pushes away from blocked cells within one step ahead,
+// ignoring the cell being sought
+func (e *Agent) avoidObstacles(world *World, seek common.Vector2D) common.Vector2D {
+	var push common.Vector2D
+	cx, cy := cellOf(e.Position)
+	tx, ty := cellOf(seek)
+	for y := cy - 1; y <= cy+1; y++ {
+		for x := cx - 1; x <= cx+1; x++ {
+			if (x == cx && y == cy) || (x == tx && y == ty) || world.IsWalkable(x, y) {
+				continue
+			}
+			away := e.Position.Subtract(common.Vector2D{X: float64(x) + 0.5, Y: float64(y) + 0.5})
+			if away.Dot(e.Direction) > 0 && !e.Direction.IsZero() {
+				continue // Already moving away from this cell
+			}
+			if dist := away.Length(); dist > 0 {
+				push = push.Add(away.Normalize().Scale(1 / dist))
+			}
+		}
+	}
+	return push
+}
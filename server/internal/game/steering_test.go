@@ -0,0 +1,54 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// steerGoat returns where a goat at (5.5, 5.5) with a herd mate just below it
+// heads to reach (15.5, 5.5), under the given goat weights
+func steerGoat(t *testing.T, state common.EntityState, normal, fleeing SteeringWeights) common.Vector2D {
+	t.Helper()
+	w := newEmptyWorld(t, 20)
+	w.Config.Steering[common.EntityTypeGoat] = SteeringProfile{Normal: normal, Fleeing: fleeing}
+	id := w.SpawnGoat(common.Vector2D{X: 5.5, Y: 5.5})
+	mate := w.SpawnGoat(common.Vector2D{X: 5.5, Y: 6.2})
+	w.captureView()
+	a, ok := w.agent(id)
+	if !ok {
+		t.Fatal("goat has no agent")
+	}
+	a.State = state
+	a.Perception.VisibleEntities = []int{mate}
+	return a.steer(w, common.Vector2D{X: 15.5, Y: 5.5}, true)
+}
+
+func TestSteeringWeights(t *testing.T) {
+	seek := SteeringWeights{Seek: 1, NeighborRadius: 6, SeparationRadius: 1.5}
+	separate := seek
+	separate.Separation = 2
+	cohere := seek
+	cohere.Cohesion = 1
+
+	if h := steerGoat(t, common.EntityStateMoving, seek, seek); math.Abs(h.X-1) > 1e-9 || h.Y != 0 {
+		t.Errorf("seek only heads %v, want straight at the target", h)
+	}
+	if h := steerGoat(t, common.EntityStateMoving, separate, seek); h.Y >= 0 {
+		t.Errorf("separation heads %v, want away from the mate below", h)
+	}
+	if h := steerGoat(t, common.EntityStateMoving, cohere, seek); h.Y <= 0 {
+		t.Errorf("cohesion heads %v, want toward the mate below", h)
+	}
+	// Fleeing switches to the fleeing weights
+	if h := steerGoat(t, common.EntityStateFleeing, seek, separate); h.Y >= 0 {
+		t.Errorf("fleeing heads %v, want the fleeing separation", h)
+	}
+
+	arrive := seek
+	arrive.ArrivalRadius = 40
+	if h := steerGoat(t, common.EntityStateMoving, arrive, seek); math.Abs(h.Length()-0.5) > 1e-9 {
+		t.Errorf("speed %g inside the arrival radius, want 0.5", h.Length())
+	}
+}
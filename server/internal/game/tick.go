@@ -0,0 +1,267 @@
+package game
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// A tick runs the systems below in order. The perception and brain systems
+// form the read phase: they run in parallel across entities, read other
+// entities only through the views captured at the start of the tick and
+// write only the entity's own components. Changes to the shared world are
+// queued as intents. The write phase then applies intents, spawns,
+// collisions and metabolism sequentially in ID order, so a seeded world plays
+// out the same regardless of the worker count.
+
+// System updates the world's components once per tick
+type System struct {
+	Name string
+	Run  func(w *World)
+}
+
+var systems = []System{
+	{"climate", (*World).climateSystem},
+	{"view", (*World).captureView},
+	{"shelter", (*World).shelterSystem},
+	{"perception", (*World).perceptionSystem},
+	{"brain", (*World).brainSystem},
+	{"intents", (*World).applyIntents},
+	{"spawn", (*World).applyPending},
+	{"collision", (*World).resolveCollisions},
+	{"metabolism", (*World).applyMetabolism},
+	{"stop", (*World).stopSystem},
+	{"state", (*World).stateSystem},
+	{"snapshot", (*World).publishSnapshot},
+	{"events", (*World).eventSystem},
+}
+
+// Systems lists the names of the systems in the order they run
+func Systems() []string {
+	names := make([]string, len(systems))
+	for i, s := range systems {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Components lists what a new entity starts with, nil components are left out
+type Components struct {
+	Body       Body
+	Transform  Transform
+	Motion     *Motion
+	Stats      *common.Stats
+	Perception *Perception
+	Brain      *Brain
+}
+
+type pendingSpawn struct {
+	id int
+	c  Components
+}
+
+// Add spawns an entity with the given components and returns its ID. During
+// a tick entities call it from an intent, the new entity appears once all
+// intents have been applied.
+func (w *World) Add(c Components) int {
+	w.nextID++
+	id := w.nextID
+	if w.ticking {
+		w.spawns = append(w.spawns, pendingSpawn{id, c})
+	} else {
+		w.addComponents(id, c)
+	}
+	return id
+}
+
+func (w *World) addComponents(id int, c Components) {
+	w.Bodies.Add(id, c.Body)
+	w.Transforms.Add(id, c.Transform)
+	if c.Motion != nil {
+		w.Motions.Add(id, *c.Motion)
+	}
+	if c.Stats != nil {
+		w.Stats.Add(id, *c.Stats)
+	}
+	if c.Perception != nil {
+		w.Perceptions.Add(id, *c.Perception)
+	}
+	if c.Brain != nil {
+		w.Brains.Add(id, *c.Brain)
+	}
+	w.emit(Event{
+		Kind:       EventSpawned,
+		EntityID:   id,
+		EntityType: c.Body.Type,
+		Position:   c.Transform.Position,
+	})
+}
+
+// RemoveEntity removes an entity and all its components. During a tick
+// entities call it from an intent, the entity disappears once all intents
+// have been applied, use Alive to check.
+func (w *World) RemoveEntity(id int) {
+	w.Kill(id, 0)
+}
+
+// Kill removes an entity like RemoveEntity and records killer as the cause
+// of death, 0 when there is none
+func (w *World) Kill(id, killer int) {
+	if !w.Alive(id) {
+		return
+	}
+	e := Event{Kind: EventDied, EntityID: id, EntityType: w.Bodies.Get(id).Type, OtherID: killer}
+	if t := w.Transforms.Get(id); t != nil {
+		e.Position = t.Position
+	}
+	w.emit(e)
+
+	if w.ticking {
+		w.despawns = append(w.despawns, id)
+	} else {
+		w.removeComponents(id)
+	}
+}
+
+func (w *World) removeComponents(id int) {
+	w.Bodies.Remove(id)
+	w.Transforms.Remove(id)
+	w.Motions.Remove(id)
+	w.Stats.Remove(id)
+	w.Perceptions.Remove(id)
+	w.Brains.Remove(id)
+}
+
+// Alive reports whether the entity exists and has not been removed this tick
+func (w *World) Alive(id int) bool {
+	return w.Bodies.Has(id) && !slices.Contains(w.despawns, id)
+}
+
+// EntityCount returns the number of entities in the world
+func (w *World) EntityCount() int {
+	return w.Bodies.Len()
+}
+
+// applyPending adds and removes the entities queued during the tick. It runs
+// after the intents, once no agent handle into the stores is used anymore.
+func (w *World) applyPending() {
+	for _, s := range w.spawns {
+		w.addComponents(s.id, s.c)
+	}
+	for _, id := range w.despawns {
+		w.removeComponents(id)
+	}
+	w.spawns, w.despawns = w.spawns[:0], w.despawns[:0]
+}
+
+// Intend queues a change to the world or to other entities. It runs in the
+// write phase after every entity has ticked, in entity ID order, and must
+// re-check anything it relies on since earlier intents may have changed it.
+func (a *Agent) Intend(fn func(world *World)) {
+	a.brain.intents = append(a.brain.intents, fn)
+}
+
+// workers returns how many goroutines tick entities
+func (w *World) workers() int {
+	if w.Config.Workers > 0 {
+		return w.Config.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallel calls fn for every index below n across the worker pool
+func (w *World) parallel(n int, fn func(i int)) {
+	workers := min(w.workers(), n)
+	if workers <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// perceptionSystem refreshes what every entity sees
+func (w *World) perceptionSystem() {
+	w.resources = w.resources[:0]
+	w.resources = append(w.resources, w.StaticObstacles.WaterSources...)
+	w.resources = append(w.resources, w.StaticObstacles.FoodSources...)
+	w.resources = append(w.resources, w.StaticObstacles.RestAreas...)
+
+	light := w.Clock().Light
+	w.parallel(w.Perceptions.Len(), func(i int) {
+		id := w.Perceptions.ID(i)
+		if t := w.Transforms.Get(id); t != nil {
+			w.perceive(id, t, w.Perceptions.At(i), light)
+		}
+	})
+}
+
+// brainSystem ticks every entity's behavior tree
+func (w *World) brainSystem() {
+	w.parallel(w.Brains.Len(), func(i int) {
+		id, b := w.Brains.ID(i), w.Brains.At(i)
+		b.intents, b.events = b.intents[:0], b.events[:0]
+		a, ok := w.agent(id)
+		if !ok {
+			return
+		}
+		b.seedRand(w, id)
+		b.think(a, w)
+	})
+}
+
+// seedRand derives the brain's random source from the world seed, the tick
+// and the entity ID, so its draws don't depend on which worker ticks it
+func (b *Brain) seedRand(w *World, id int) {
+	if b.rng == nil {
+		b.pcg = &rand.PCG{}
+		b.rng = rand.New(b.pcg)
+	}
+	b.pcg.Seed(w.Config.Seed, uint64(w.tick)<<32|uint64(uint32(id)))
+}
+
+// applyIntents runs the queued intents and collects the events of every
+// entity in ID order
+func (w *World) applyIntents() {
+	for _, b := range w.Brains.All() {
+		for _, e := range b.events {
+			w.emit(e)
+		}
+		b.events = b.events[:0]
+		for _, fn := range b.intents {
+			fn(w)
+		}
+		b.intents = b.intents[:0]
+	}
+}
+
+// stopSystem brings entities that did not move this tick to a stop
+func (w *World) stopSystem() {
+	for id, m := range w.Motions.All() {
+		t, v := w.Transforms.Get(id), w.View(id)
+		if t != nil && v != nil && t.Position.SameAs(v.Position) {
+			m.Speed = 0
+		}
+	}
+}
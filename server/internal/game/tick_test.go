@@ -0,0 +1,60 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/xSaCh/animalia/internal/common"
+)
+
+// The number of workers must not change the simulation: entities read the
+// view of the tick's start and intents apply in ID order
+func TestTickDeterministicAcrossWorkers(t *testing.T) {
+	run := func(workers int) []byte {
+		w := newTestWorld(t)
+		w.Config.Workers = workers
+		for range 400 {
+			w.Tick()
+		}
+		data, err := json.Marshal(w.Snapshot().Entities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	want := run(1)
+	if got := run(8); !bytes.Equal(got, want) {
+		t.Error("8 workers diverged from 1 worker after 400 ticks")
+	}
+}
+
+// A world asked to run at 0 tps still ticks, at the slowest rate
+func TestTickZeroTPS(t *testing.T) {
+	w := NewWorld(20, 0)
+	pos, err := w.GetRandomWalkablePosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SpawnGoat(pos)
+	for range 50 {
+		w.Tick()
+	}
+	if w.Config.TPS != 1 || w.DeltaTime() != 1 {
+		t.Fatalf("tps %d, delta time %g", w.Config.TPS, w.DeltaTime())
+	}
+}
+
+func BenchmarkPerception(b *testing.B) {
+	w := newEmptyWorld(b, 200)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 5000 {
+		w.SpawnGoat(common.Vector2D{X: 200 * rng.Float64(), Y: 200 * rng.Float64()})
+	}
+	w.captureView()
+	for b.Loop() {
+		w.perceptionSystem()
+	}
+}
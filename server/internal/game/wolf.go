@@ -0,0 +1,301 @@
+package game
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game/btree"
+	"github.com/xSaCh/animalia/internal/game/goap"
+)
+
+// preyMeal is how much hunger a killed goat feeds
+const preyMeal = 80
+
+// Wolf is the blackboard of a wolf's behavior tree
+type Wolf struct {
+	Agent
+
+	preyID int // ID of the goat being hunted, 0 when none
+}
+
+// SpawnWolf adds a wolf with appropriate initial values and returns its ID
+func (w *World) SpawnWolf(position common.Vector2D) int {
+	brain := NewBrain(createWolfBehaviorTree(), &Wolf{})
+	return w.Add(Components{
+		Body: Body{
+			Type:   common.EntityTypeWolf,
+			State:  common.EntityStateRoaming,
+			Radius: 0.4,
+		},
+		Transform: Transform{Position: position},
+		Motion: &Motion{
+			Gait: common.GaitWalk,
+			Movement: Movement{
+				WalkSpeed:    3.5,
+				RunSpeed:     8,
+				Acceleration: 8,
+			},
+		},
+		Stats: &common.Stats{
+			Hunger:    50, // Wolves start half hungry so they hunt early
+			Thirst:    25,
+			Tiredness: 20,
+		},
+		Perception: &Perception{
+			Radius:      12,
+			FOV:         200 * math.Pi / 180,
+			NightVision: 0.9, // Wolves hunt by night
+		},
+		Brain: &brain,
+	})
+}
+
+// wolfMemory is what a wolf remembers across saves
+type wolfMemory struct {
+	PreyID int `json:"prey_id,omitempty"`
+}
+
+func (wf *Wolf) saveMemory() (json.RawMessage, error) {
+	return json.Marshal(wolfMemory{PreyID: wf.preyID})
+}
+
+func (wf *Wolf) loadMemory(data json.RawMessage) error {
+	var m wolfMemory
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	wf.preyID = m.PreyID
+	return nil
+}
+
+// prey returns the hunted goat as it was at the start of the tick, or nil
+// once it is gone
+func (wf *Wolf) prey(world *World) *EntityView {
+	if wf.preyID == 0 {
+		return nil
+	}
+	return world.View(wf.preyID)
+}
+
+func createWolfBehaviorTree() btree.Node {
+	idGen := btree.NewIDGenerator()
+
+	sense := func(ctx *btree.TickContext) goap.State {
+		wolf := ctx.BlackBoard.(*Wolf)
+		world := ctx.World.(*World)
+
+		state := goap.State{
+			"fed":       wolf.Stats.Hunger < 30 && wolf.Meal == 0,
+			"hydrated":  wolf.Stats.Thirst < 30,
+			"rested":    wolf.Stats.Tiredness < 30,
+			"has_prey":  false,
+			"near_prey": false,
+			"has_meal":  wolf.Meal > 0,
+			"at_water":  false,
+		}
+		if prey := wolf.prey(world); prey != nil {
+			state["has_prey"] = true
+			state["near_prey"] = wolf.Position.Distance(prey.Position) <= 1
+		}
+		if _, dist := world.GetNearestWaterSourcePos(wolf.Position); dist <= interactionRange {
+			state["at_water"] = true
+		}
+		return state
+	}
+
+	locatePrey := &goap.Action{
+		Name:    "locate_prey",
+		Effects: goap.State{"has_prey": true},
+		Cost:    1,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			world := ctx.World.(*World)
+
+			// Only goats the wolf can see are candidates
+			wolf.preyID = 0
+			// Goats sheltering in a rest area or across water are off limits
+			prey, _ := wolf.NearestVisibleFunc(world, func(v *EntityView) bool {
+				return v.Type == common.EntityTypeGoat && !v.Sheltered && world.Connected(wolf.Position, v.Position)
+			})
+			if prey == nil {
+				return btree.Failure
+			}
+			wolf.preyID = prey.ID
+			return btree.Success
+		},
+	}
+	chasePrey := &goap.Action{
+		Name:          "chase_prey",
+		Preconditions: goap.State{"has_prey": true},
+		Effects:       goap.State{"near_prey": true},
+		Cost:          2,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			world := ctx.World.(*World)
+			prey := wolf.prey(world)
+			if prey == nil || prey.Sheltered {
+				wolf.preyID = 0
+				return btree.Failure
+			}
+			preyPos := prey.Position
+			if wolf.Position.Distance(preyPos) <= 1 {
+				return btree.Success
+			}
+			wolf.TargetPos = &preyPos
+			wolf.Gait = common.GaitRun
+			wolf.MoveTowardTarget(world)
+			wolf.State = common.EntityStateMoving
+			return btree.Running
+		},
+	}
+	killPrey := &goap.Action{
+		Name:          "kill_prey",
+		Preconditions: goap.State{"near_prey": true},
+		Effects:       goap.State{"has_meal": true, "has_prey": false, "near_prey": false},
+		Cost:          1,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			world := ctx.World.(*World)
+			if wolf.prey(world) == nil {
+				return btree.Failure
+			}
+			// Another wolf may kill the same goat first this tick, only the
+			// lowest ID gets to eat it
+			preyID := wolf.preyID
+			wolf.Intend(func(world *World) {
+				if !world.Alive(preyID) || world.View(preyID).Sheltered {
+					return
+				}
+				world.Kill(preyID, wolf.ID)
+				wolf.Meal += preyMeal
+			})
+			wolf.preyID = 0
+			wolf.TargetPos = nil
+			return btree.Success
+		},
+	}
+	// Metabolism turns the meal into hunger recovered while eating
+	eat := &goap.Action{
+		Name:          "eat",
+		Preconditions: goap.State{"has_meal": true},
+		Effects:       goap.State{"fed": true, "has_meal": false},
+		Cost:          1,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			if wolf.Stats.Hunger <= 20 {
+				wolf.Meal = 0 // Leaves the rest
+				return btree.Success
+			}
+			if wolf.Meal <= 0 {
+				return btree.Failure // Another wolf got the prey, or it ran out
+			}
+			wolf.State = common.EntityStateEating
+			return btree.Running
+		},
+	}
+	goToWater := &goap.Action{
+		Name:    "go_to_water",
+		Effects: goap.State{"at_water": true},
+		Cost:    2,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			world := ctx.World.(*World)
+
+			_, dist := world.GetNearestWaterSourcePos(wolf.Position)
+			if math.IsInf(dist, 1) {
+				return btree.Failure
+			}
+			if dist <= interactionRange {
+				return btree.Success
+			}
+			waterPos, _ := world.NearestWaterApproach(wolf.Position)
+			wolf.TargetPos = &waterPos
+			wolf.MoveTowardTarget(world)
+			wolf.State = common.EntityStateMoving
+			return btree.Running
+		},
+	}
+	drink := &goap.Action{
+		Name:          "drink",
+		Preconditions: goap.State{"at_water": true},
+		Effects:       goap.State{"hydrated": true},
+		Cost:          1,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			world := ctx.World.(*World)
+			if _, dist := world.GetNearestWaterSourcePos(wolf.Position); dist > resourceReach {
+				return btree.Failure // Drank it dry, replan
+			}
+			wolf.State = common.EntityStateDrinking
+			if wolf.Stats.Thirst <= 20 {
+				wolf.TargetPos = nil
+				return btree.Success
+			}
+			return btree.Running
+		},
+	}
+	rest := &goap.Action{
+		Name:    "rest",
+		Effects: goap.State{"rested": true},
+		Cost:    1,
+		Perform: func(ctx *btree.TickContext) btree.Status {
+			wolf := ctx.BlackBoard.(*Wolf)
+			wolf.State = common.EntityStateResting
+			if wolf.Stats.Tiredness <= 20 {
+				return btree.Success
+			}
+			return btree.Running
+		},
+	}
+
+	planner := goap.NewPlanner(locatePrey, chasePrey, killPrey, eat, goToWater, drink, rest)
+	need := func(stat func(*Wolf) float64, threshold float64) func(*btree.TickContext) float64 {
+		return func(ctx *btree.TickContext) float64 {
+			value := stat(ctx.BlackBoard.(*Wolf))
+			if value < threshold {
+				return 0
+			}
+			return value / 100
+		}
+	}
+	// Dusk is hunting time, wolves go after prey even when only a little hungry
+	hunger := func(w *Wolf) float64 { return w.Stats.Hunger }
+	hunt, duskHunt := need(hunger, 70), need(hunger, 40)
+	huntPriority := func(ctx *btree.TickContext) float64 {
+		// A meal at hand takes seconds to eat, so it comes before any other
+		// need and the wolf doesn't walk off half way through
+		if ctx.BlackBoard.(*Wolf).Meal > 0 {
+			return 1.5
+		}
+		if ctx.World.(*World).Clock().Phase == PhaseDusk {
+			return duskHunt(ctx)
+		}
+		return hunt(ctx)
+	}
+	goals := []*goap.Goal{
+		{Name: "eat", Desired: goap.State{"fed": true}, Priority: huntPriority},
+		{Name: "drink", Desired: goap.State{"hydrated": true}, Priority: need(func(w *Wolf) float64 { return w.Stats.Thirst }, 80)},
+		{Name: "sleep", Desired: goap.State{"rested": true}, Priority: need(func(w *Wolf) float64 { return w.Stats.Tiredness }, 85)},
+	}
+
+	// Roaming takes a single step and succeeds, so the planner gets another
+	// chance every tick instead of the selector resuming a running roam
+	roam := func(ctx *btree.TickContext) btree.Status {
+		wolf := ctx.BlackBoard.(*Wolf)
+		world := ctx.World.(*World)
+
+		if wolf.AtTarget() {
+			roamPos := wolf.randomWalkablePosition(world)
+			wolf.TargetPos = &roamPos
+		}
+		wolf.MoveTowardTarget(world)
+		wolf.State = common.EntityStateRoaming
+		return btree.Success
+	}
+
+	return btree.NewSelector(idGen.Next(),
+		goap.NewPlannerNode(idGen.Next(), planner, sense, goals...).Named("needs"),
+		btree.NewAction(idGen.Next(), roam).Named("roam"),
+	).Named("wolf")
+}
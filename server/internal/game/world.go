@@ -1,160 +1,362 @@
 package game
 
 import (
+	"encoding/json"
 	"fmt"
-	"math/rand"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sync/atomic"
 
 	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game/ecs"
 )
 
+// roughTerrainSpeed is the speed multiplier of rough ground cells
+const roughTerrainSpeed = 0.6
+
+// MaxTPS is the highest tick rate a world runs at. Faster rates would tick
+// too often to ever keep up.
+const MaxTPS = 1000
+
 type Config struct {
-	TPS int `json:"tps"` // Ticks per second
+	TPS         int                                   `json:"tps"`           // Ticks per second
+	TicksPerDay int                                   `json:"ticks_per_day"` // Length of an in-game day
+	Seed        uint64                                `json:"seed"`          // Random seed, 0 picks one when the world is created
+	Workers     int                                   `json:"workers"`       // Goroutines ticking entities, 0 uses GOMAXPROCS
+	Metabolism  map[common.EntityType]Metabolism      `json:"metabolism"`
+	Steering    map[common.EntityType]SteeringProfile `json:"steering"`
+	Climate     Climate                               `json:"climate"`
+}
+
+// daySeconds is the default length of an in-game day, four minutes
+const daySeconds = 240
+
+// DefaultConfig returns the built-in configuration running at tps
+func DefaultConfig(tps int) Config {
+	return Config{
+		TPS:         tps,
+		TicksPerDay: daySeconds * tps,
+		Metabolism:  DefaultMetabolism(),
+		Steering:    DefaultSteering(),
+		Climate:     DefaultClimate(),
+	}
+}
+
+// clone deep copies the config so snapshots don't share its maps
+func (c Config) clone() Config {
+	c.Metabolism = maps.Clone(c.Metabolism)
+	for t, m := range c.Metabolism {
+		c.Metabolism[t] = maps.Clone(m)
+	}
+	c.Steering = maps.Clone(c.Steering)
+	c.Climate.Seasons = maps.Clone(c.Climate.Seasons)
+	for s, p := range c.Climate.Seasons {
+		p.Odds = maps.Clone(p.Odds)
+		c.Climate.Seasons[s] = p
+	}
+	c.Climate.Weather = maps.Clone(c.Climate.Weather)
+	return c
+}
+
+// LoadConfig reads a JSON config file on top of DefaultConfig(tps). Species
+// listed under "metabolism" or "steering" replace the defaults of that
+// species.
+func LoadConfig(path string, tps int) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DefaultConfig(tps), err
+	}
+	cfg, err := ParseConfig(data, tps)
+	if err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig decodes JSON on top of DefaultConfig(tps) like LoadConfig.
+// Unless the JSON sets ticks_per_day, days last as long at the decoded tps
+// as they do by default.
+func ParseConfig(data []byte, tps int) (Config, error) {
+	cfg := DefaultConfig(tps)
+	cfg.TicksPerDay = 0
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return DefaultConfig(tps), err
+	}
+	if cfg.TPS <= 0 || cfg.TPS > MaxTPS {
+		return cfg, fmt.Errorf("tps must be between 1 and %d, got %d", MaxTPS, cfg.TPS)
+	}
+	if cfg.TicksPerDay == 0 {
+		cfg.TicksPerDay = daySeconds * cfg.TPS
+	}
+	if cfg.TicksPerDay <= 0 {
+		return cfg, fmt.Errorf("ticks_per_day must be positive, got %d", cfg.TicksPerDay)
+	}
+	if cfg.Workers < 0 {
+		return cfg, fmt.Errorf("workers must not be negative, got %d", cfg.Workers)
+	}
+	return cfg, nil
 }
 
 // World represents the game world
 type World struct {
-	ID              int                    `json:"id"`
+	ID              int                    `json:"id"` // Set by whoever hosts several worlds
 	Width           float64                `json:"width"`
 	Height          float64                `json:"height"`
 	NavigationGrid  [][]bool               `json:"navigation_grid"` // true = walkable, false = blocked
+	Terrain         [][]float64            `json:"terrain"`         // Speed multiplier per cell, 1 = open ground
 	StaticObstacles common.StaticObstacles `json:"static_obstacles"`
-	Entities        []Entity               `json:"entities"`
 	Config          Config                 `json:"config"`
 
-	tick uint
+	// Component stores, read them through Snapshot outside of ticks
+	Bodies      ecs.Store[Body]         `json:"-"`
+	Transforms  ecs.Store[Transform]    `json:"-"`
+	Motions     ecs.Store[Motion]       `json:"-"`
+	Stats       ecs.Store[common.Stats] `json:"-"`
+	Perceptions ecs.Store[Perception]   `json:"-"`
+	Brains      ecs.Store[Brain]        `json:"-"`
+
+	Events EventBus `json:"-"` // Receives every tick's events
+
+	tick        uint
+	nextID      int
+	ticking     bool
+	spawns      []pendingSpawn // Queued by Add during a tick
+	despawns    []int          // Queued by RemoveEntity during a tick
+	events      []Event        // This tick's batch so far
+	weather     WeatherState
+	rng         *rand.Rand
+	pcg         *rand.PCG               // Source of rng, kept to save its state
+	regions     regionMap               // Connected regions of the navigation grid
+	hpa         *hpaGraph               // Cluster graph for long paths
+	flows       map[cell]*flowField     // Flow field of each resource, by its position's cell
+	shoreFields map[cell][]*flowField   // Fields leading to each shore cell
+	flowDirty   *area                   // Cells changed since the flow fields were updated, nil when none
+	view        worldView               // Entities as they were at the start of the tick
+	resources   []common.StaticObstacle // Perceivable resources, gathered each tick
+	opaque      [][]bool                // Cells that block line of sight, same layout as NavigationGrid
+
+	snapshot    atomic.Pointer[Snapshot]
+	mapSnapshot *MapSnapshot // Shared by snapshots until mapVersion changes
+	mapVersion  uint         // Bumped whenever the grid, terrain or obstacles change
 }
 
+// NewWorld creates a world with the default config and a random seed
 func NewWorld(size int, tps int) *World {
-	// SIZE := 30
-	grid := make([][]bool, size)
-	for i := range grid {
-		grid[i] = make([]bool, size)
+	return NewWorldWithConfig(size, DefaultConfig(tps))
+}
+
+// NewWorldWithConfig creates a world whose map and simulation are derived
+// from cfg.Seed, the same seed and entities always play out the same. A TPS
+// outside 1..MaxTPS is clamped into it, and days of no ticks last as long
+// as by default.
+func NewWorldWithConfig(size int, cfg Config) *World {
+	cfg.TPS = min(max(cfg.TPS, 1), MaxTPS)
+	if cfg.TicksPerDay <= 0 {
+		cfg.TicksPerDay = daySeconds * cfg.TPS
 	}
-	for x := range size {
-		for y := range size {
+	if cfg.Seed == 0 {
+		cfg.Seed = rand.Uint64()
+	}
+	pcg := rand.NewPCG(cfg.Seed, 0)
+	rng := rand.New(pcg)
+
+	grid := make([][]bool, size)
+	opaque := make([][]bool, size)
+	terrain := make([][]float64, size)
+	for y := range size {
+		grid[y] = make([]bool, size)
+		opaque[y] = make([]bool, size)
+		terrain[y] = make([]float64, size)
+		for x := range size {
 			grid[y][x] = true
+			terrain[y][x] = 1
 		}
 	}
-	// Random 5 water sources
-	waters := make([]common.StaticObstacle, 0)
-	for range 5 {
-		x := rand.Intn(size)
-		y := rand.Intn(size)
-		waters = append(waters, common.StaticObstacle{
-			Position: common.Vector2D{X: float64(x), Y: float64(y)},
-			Type:     common.ObstacleTypeWaterSource})
-		grid[y][x] = false
-	}
-	// Random 10 food sources
-	foods := make([]common.StaticObstacle, 0)
-	for range 10 {
-		x := rand.Intn(size)
-		y := rand.Intn(size)
-		foods = append(foods, common.StaticObstacle{
-			Position: common.Vector2D{X: float64(x), Y: float64(y)},
-			Type:     common.ObstacleTypeFoodSource})
-		grid[y][x] = false
-	}
-	// Random 10 obstacles
-	obstacles := make([]common.StaticObstacle, 0)
-	for range 10 {
-		x := rand.Intn(size)
-		y := rand.Intn(size)
-		obstacles = append(obstacles, common.StaticObstacle{
-			Position: common.Vector2D{X: float64(x), Y: float64(y)},
-			Type:     common.ObstacleTypeWall})
-		grid[y][x] = false
-	}
-	return &World{
-		ID:             001,
+	w := &World{
+		ID:             1,
 		Width:          float64(size),
 		Height:         float64(size),
 		NavigationGrid: grid,
+		Terrain:        terrain,
 		StaticObstacles: common.StaticObstacles{
-			Walls:        obstacles,
-			WaterSources: waters,
-			FoodSources:  foods,
+			Walls:        make([]common.StaticObstacle, 0),
+			WaterSources: make([]common.StaticObstacle, 0),
+			FoodSources:  make([]common.StaticObstacle, 0),
 			RestAreas:    make([]common.StaticObstacle, 0),
 		},
-		Entities: make([]Entity, 0),
-		Config:   Config{TPS: tps},
+		Config: cfg,
+		rng:    rng,
+		pcg:    pcg,
+		opaque: opaque,
+	}
+
+	// Random 2 lakes and 3 rock ridges, skipped when they run off the map
+	for range 2 {
+		center := common.Vector2D{X: float64(rng.IntN(size)), Y: float64(rng.IntN(size))}
+		if o, err := newShapedObstacle(common.ObstacleTypeWaterSource, blobShape(center, 2+2*rng.Float64(), 8, rng)); err == nil {
+			w.placeObstacle(o)
+		}
+	}
+	for range 3 {
+		x, y, length := rng.IntN(size), rng.IntN(size), 2+rng.IntN(5)
+		shape := RectShape(x, y, x+length, y)
+		if rng.IntN(2) == 0 {
+			shape = RectShape(x, y, x, y+length)
+		}
+		if o, err := newShapedObstacle(common.ObstacleTypeWall, shape); err == nil {
+			w.placeObstacle(o)
+		}
 	}
+	// Random 5 water sources, 10 food sources and 10 walls. A cell that is
+	// drawn twice keeps its first obstacle.
+	for _, place := range []struct {
+		t     common.ObstacleType
+		count int
+	}{
+		{common.ObstacleTypeWaterSource, 5},
+		{common.ObstacleTypeFoodSource, 10},
+		{common.ObstacleTypeWall, 10},
+	} {
+		for range place.count {
+			o, _ := newObstacle(place.t, rng.IntN(size), rng.IntN(size))
+			w.placeObstacle(o)
+		}
+	}
+	// Random 6 patches of rough ground that slow entities down
+	for range 6 {
+		cx, cy := rng.IntN(size), rng.IntN(size)
+		r := 2 + rng.IntN(4)
+		for y := max(0, cy-r); y < min(size, cy+r); y++ {
+			for x := max(0, cx-r); x < min(size, cx+r); x++ {
+				terrain[y][x] = roughTerrainSpeed
+			}
+		}
+	}
+	w.labelRegions()
+	w.connectResources(rng)
+	w.buildHierarchy()
+	w.buildFlowFields()
+
+	// Random 4 rest areas on open ground
+	for range 4 {
+		center, err := w.randomPositionIn(rng, 0)
+		if err != nil {
+			break
+		}
+		w.StaticObstacles.RestAreas = append(w.StaticObstacles.RestAreas,
+			NewRestArea(center, restAreaRadius, restAreaCapacity))
+	}
+	w.publishSnapshot()
+	return w
 }
 
 func (w *World) GetTick() uint {
 	return w.tick
 }
 
-func (w *World) Tick() {
+// DeltaTime returns the simulated seconds covered by one tick
+func (w *World) DeltaTime() float64 {
+	return 1 / float64(w.Config.TPS)
+}
 
-	/*
-		- Execute Current State (via behavior tree for Goats, legacy for Entities)
-		- Update Entity State
-		- Handle Collisions
-		- Update Entity Stats
-	*/
-	w.tick++
+// SpeedModifier returns the terrain speed multiplier of a cell, in (0, 1]
+func (w *World) SpeedModifier(x, y int) float64 {
+	if y < 0 || y >= len(w.Terrain) || x < 0 || x >= len(w.Terrain[y]) {
+		return 1
+	}
+	return w.Terrain[y][x]
+}
 
-	// Tick goats using behavior trees
-	for _, goat := range w.Entities {
-		goat.Tick(w)
+// Tick advances the world by running every system in order
+func (w *World) Tick() {
+	w.tick++
+	w.ticking = true
+	for _, s := range systems {
+		s.Run(w)
 	}
+	w.ticking = false
 }
 
-func (w *World) GetRandomWalkablePosition() common.Vector2D {
-	for {
-		gridX := rand.Intn(len(w.NavigationGrid))
-		gridY := rand.Intn(len(w.NavigationGrid[0]))
-		pos := common.Vector2D{X: float64(gridX), Y: float64(gridY)}
-		if w.NavigationGrid[gridX][gridY] {
-			return pos
-		}
+// IsWalkable reports whether the grid cell exists and is not blocked
+func (w *World) IsWalkable(x, y int) bool {
+	if y < 0 || y >= len(w.NavigationGrid) || x < 0 || x >= len(w.NavigationGrid[y]) {
+		return false
 	}
+	return w.NavigationGrid[y][x]
+}
+
+// GetRandomWalkablePosition returns a cell of the largest connected region,
+// from where every resource can be reached, or ErrNoWalkableCell. It draws
+// from the world's random source, use it outside of ticks. Entities draw
+// from their own with randomWalkablePosition.
+func (w *World) GetRandomWalkablePosition() (common.Vector2D, error) {
+	return w.randomPositionIn(w.rng, 0)
 }
 
 func (w *World) GetRandomWaterSourcePos() common.Vector2D {
 	if len(w.StaticObstacles.WaterSources) == 0 {
 		return common.Vector2D{}
 	}
-	return w.StaticObstacles.WaterSources[rand.Intn(len(w.StaticObstacles.WaterSources))].Position
+	return w.StaticObstacles.WaterSources[w.rng.IntN(len(w.StaticObstacles.WaterSources))].Position
 }
 
 func (w *World) GetRandomFoodSourcePos() common.Vector2D {
 	if len(w.StaticObstacles.FoodSources) == 0 {
 		return common.Vector2D{}
 	}
-	return w.StaticObstacles.FoodSources[rand.Intn(len(w.StaticObstacles.FoodSources))].Position
-}
-
-// Temp
-func (w *World) RandomGoatEntity() *BaseEntity {
-	id := len(w.Entities) + 1
-	pos := w.GetRandomWalkablePosition()
-	return &BaseEntity{
-		ID:        id,
-		Type:      common.EntityTypeGoat,
-		Position:  pos,
-		State:     common.EntityStateRoaming,
-		Direction: common.Vector2D{X: 0, Y: 0},
-		Stats: common.Stats{
-			Hunger:    0,
-			Thirst:    0,
-			Tiredness: 0,
-		},
+	return w.StaticObstacles.FoodSources[w.rng.IntN(len(w.StaticObstacles.FoodSources))].Position
+}
+
+// GetNearestWaterSourcePos returns the closest point of the nearest water
+// source that has not dried up and its distance, infinite when there is none
+func (w *World) GetNearestWaterSourcePos(pos common.Vector2D) (common.Vector2D, float64) {
+	return w.nearestResource(w.StaticObstacles.WaterSources, pos)
+}
+
+// GetNearestFoodSourcePos returns the closest point of the nearest food
+// source with food left and its distance, infinite when there is none
+func (w *World) GetNearestFoodSourcePos(pos common.Vector2D) (common.Vector2D, float64) {
+	return w.nearestResource(w.StaticObstacles.FoodSources, pos)
+}
+
+// nearestResource skips sources that can't be reached from pos
+func (w *World) nearestResource(sources []common.StaticObstacle, pos common.Vector2D) (common.Vector2D, float64) {
+	nearest, best := common.Vector2D{}, math.Inf(1)
+	region := w.Region(pos)
+	for _, o := range sources {
+		if o.Amount <= 0 || !w.reachable(o, region) {
+			continue
+		}
+		if p, d := closestPoint(o, pos); d < best {
+			nearest, best = p, d
+		}
 	}
+	return nearest, best
 }
+
 func (w *World) PrintEntities() {
 	// Print legacy entities
-	for _, eI := range w.Entities {
-		e := eI.GetBaseEntity()
-		fmt.Printf("ID: %d, Position: (%.2f, %.2f), State: %v, Stats: [%d %d %d]\n",
+	for _, e := range w.Snapshot().Entities {
+		fmt.Printf("ID: %d, Position: (%.2f, %.2f), State: %v, Stats: [%.0f %.0f %.0f]\n",
 			e.ID, e.Position.X, e.Position.Y, e.State, e.Stats.Hunger, e.Stats.Thirst, e.Stats.Tiredness)
 	}
 }
 
+// drawObstacle marks every cell the obstacle covers
+func (w *World) drawObstacle(grid [][]string, o common.StaticObstacle, mark string) {
+	for _, c := range obstacleCells(o) {
+		if w.inBounds(c.X, c.Y) {
+			grid[c.Y][c.X] = mark
+		}
+	}
+}
+
 func (w *World) DrawAsciiWorld() {
 	// Walkable means ` `
 	// Obstacle means `#` (white means wall, blue means water, yellow means food)
+	// Rest area means `.` in green
 	// Entity means `<Entity_ID>` (white means idle state, yellow means finding food/water, green means roaming)
 	// Target means `<Entity_ID>(but in red color)`
 
@@ -170,32 +372,29 @@ func (w *World) DrawAsciiWorld() {
 			}
 		}
 	}
+	// Place rest areas on grid
+	for y := range grid {
+		for x := range grid[y] {
+			if w.restAreaAt(common.Vector2D{X: float64(x), Y: float64(y)}) >= 0 && w.NavigationGrid[y][x] {
+				grid[y][x] = "\033[32m.\033[0m" // Green .
+			}
+		}
+	}
 	// Place obstacles on grid
 	for _, o := range w.StaticObstacles.Walls {
-		x, y := int(o.Position.X), int(o.Position.Y)
-		if x >= 0 && x < int(w.Width) && y >= 0 && y < int(w.Height) {
-			grid[y][x] = "#"
-		}
+		w.drawObstacle(grid, o, "#")
 	}
-
 	// Place water on grid
 	for _, o := range w.StaticObstacles.WaterSources {
-		x, y := int(o.Position.X), int(o.Position.Y)
-		if x >= 0 && x < int(w.Width) && y >= 0 && y < int(w.Height) {
-			grid[y][x] = "\033[34m#\033[0m" // Blue #
-		}
+		w.drawObstacle(grid, o, "\033[34m#\033[0m") // Blue #
 	}
 	// Place food on grid
 	for _, o := range w.StaticObstacles.FoodSources {
-		x, y := int(o.Position.X), int(o.Position.Y)
-		if x >= 0 && x < int(w.Width) && y >= 0 && y < int(w.Height) {
-			grid[y][x] = "\033[33m#\033[0m" // Orange #
-		}
+		w.drawObstacle(grid, o, "\033[33m#\033[0m") // Orange #
 	}
 
 	// Place entities on grid
-	for _, eI := range w.Entities {
-		e := eI.GetBaseEntity()
+	for _, e := range w.Snapshot().Entities {
 		x, y := int(e.Position.X), int(e.Position.Y)
 		if x >= 0 && x < int(w.Width) && y >= 0 && y < int(w.Height) {
 			switch e.State {
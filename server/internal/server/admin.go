@@ -0,0 +1,387 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game"
+)
+
+// The admin API inspects and changes a running world. Reads that a
+// snapshot can answer use the latest snapshot, everything else runs on the
+// world's tick goroutine through Runner.Do.
+
+// saveName limits save names to plain file names
+var saveName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func (s *Server) adminRoutes() {
+	s.mux.HandleFunc("GET /worlds/{id}/config", s.withWorld(s.handleConfig))
+	s.mux.HandleFunc("GET /worlds/{id}/entities", s.withWorld(s.handleListEntities))
+	s.mux.HandleFunc("POST /worlds/{id}/entities", s.withWorld(s.handleSpawn))
+	s.mux.HandleFunc("GET /worlds/{id}/entities/{eid}", s.withWorld(s.handleGetEntity))
+	s.mux.HandleFunc("DELETE /worlds/{id}/entities/{eid}", s.withWorld(s.handleKill))
+	s.mux.HandleFunc("POST /worlds/{id}/entities/{eid}/trace", s.withWorld(s.handleTrace(true)))
+	s.mux.HandleFunc("DELETE /worlds/{id}/entities/{eid}/trace", s.withWorld(s.handleTrace(false)))
+	s.mux.HandleFunc("PATCH /worlds/{id}/entities/{eid}/stats", s.withWorld(s.handleEditStats))
+	s.mux.HandleFunc("POST /worlds/{id}/save", s.withWorld(s.handleSave))
+	s.mux.HandleFunc("POST /worlds/{id}/load", s.withWorld(s.handleLoad))
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	writeJSON(w, http.StatusOK, runner.World.Snapshot().Config)
+}
+
+// statRange bounds one stat, both ends inclusive
+type statRange struct {
+	min, max float64
+}
+
+// entityFilter selects entities by the query of GET /entities: type, state
+// and <stat>_min / <stat>_max for hunger, thirst and tiredness
+type entityFilter struct {
+	typ   common.EntityType
+	state common.EntityState
+	stats map[string]statRange
+}
+
+func parseEntityFilter(r *http.Request) (entityFilter, error) {
+	q := r.URL.Query()
+	f := entityFilter{
+		typ:   common.EntityType(q.Get("type")),
+		state: common.EntityState(q.Get("state")),
+		stats: map[string]statRange{},
+	}
+	for _, stat := range []string{"hunger", "thirst", "tiredness"} {
+		rng := statRange{math.Inf(-1), math.Inf(1)}
+		for _, bound := range []struct {
+			suffix string
+			value  *float64
+		}{{"_min", &rng.min}, {"_max", &rng.max}} {
+			v := q.Get(stat + bound.suffix)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, fmt.Errorf("%s%s must be a number", stat, bound.suffix)
+			}
+			*bound.value = n
+		}
+		f.stats[stat] = rng
+	}
+	return f, nil
+}
+
+func (f entityFilter) match(e game.EntitySnapshot) bool {
+	if (f.typ != "" && e.Type != f.typ) || (f.state != "" && e.State != f.state) {
+		return false
+	}
+	for stat, v := range map[string]float64{"hunger": e.Stats.Hunger, "thirst": e.Stats.Thirst, "tiredness": e.Stats.Tiredness} {
+		if rng := f.stats[stat]; v < rng.min || v > rng.max {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleListEntities(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	filter, err := parseEntityFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entities := []game.EntitySnapshot{}
+	for _, e := range runner.World.Snapshot().Entities {
+		if filter.match(e) {
+			entities = append(entities, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, entities)
+}
+
+// EntityDetail is an entity with the state of its behavior tree
+type EntityDetail struct {
+	game.EntitySnapshot
+	Brain *game.BrainState `json:"brain,omitempty"`
+}
+
+// entityID parses the {eid} of the path, writing an error when it isn't one
+func entityID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("eid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("entity id must be a number"))
+		return 0, false
+	}
+	return id, true
+}
+
+// handleGetEntity returns the entity and its behavior tree. Node statuses
+// and scores are only there while the entity is traced.
+func (s *Server) handleGetEntity(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	s.writeEntityDetail(w, r, runner, nil)
+}
+
+// handleTrace starts or stops recording an entity's tree statuses, from
+// the next tick on, and returns the entity like handleGetEntity
+func (s *Server) handleTrace(on bool) func(http.ResponseWriter, *http.Request, *Runner) {
+	return func(w http.ResponseWriter, r *http.Request, runner *Runner) {
+		s.writeEntityDetail(w, r, runner, func(world *game.World, id int) {
+			world.TraceEntity(id, on)
+		})
+	}
+}
+
+// writeEntityDetail writes the entity of the path, calling before first
+// when it isn't nil
+func (s *Server) writeEntityDetail(w http.ResponseWriter, r *http.Request, runner *Runner, before func(*game.World, int)) {
+	id, ok := entityID(w, r)
+	if !ok {
+		return
+	}
+	var detail EntityDetail
+	var found bool
+	err := runner.Do(func(world *game.World) {
+		if !world.Alive(id) {
+			return
+		}
+		if before != nil {
+			before(world, id)
+		}
+		if detail.EntitySnapshot, found = world.Entity(id); found {
+			detail.Brain, _ = world.BrainState(id)
+		}
+	})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no entity %d", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// SpawnRequest adds an entity, at a random walkable position when Position
+// is left out
+type SpawnRequest struct {
+	Type     common.EntityType `json:"type"`
+	Position *common.Vector2D  `json:"position,omitempty"`
+}
+
+func (s *Server) handleSpawn(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	var req SpawnRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	var entity game.EntitySnapshot
+	var spawnErr error
+	err := runner.Do(func(world *game.World) {
+		pos := common.Vector2D{}
+		if req.Position != nil {
+			pos = *req.Position
+			if !world.IsWalkable(int(math.Floor(pos.X)), int(math.Floor(pos.Y))) {
+				spawnErr = fmt.Errorf("position %v is not walkable", pos)
+				return
+			}
+		} else if pos, spawnErr = world.GetRandomWalkablePosition(); spawnErr != nil {
+			return
+		}
+		id, ok := world.Spawn(req.Type, pos)
+		if !ok {
+			spawnErr = fmt.Errorf("unknown entity type %q", req.Type)
+			return
+		}
+		world.Publish()
+		entity, _ = world.Entity(id)
+	})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if spawnErr != nil {
+		writeError(w, http.StatusBadRequest, spawnErr)
+		return
+	}
+	writeJSON(w, http.StatusCreated, entity)
+}
+
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	id, ok := entityID(w, r)
+	if !ok {
+		return
+	}
+	var found bool
+	err := runner.Do(func(world *game.World) {
+		if found = world.Alive(id); found {
+			world.RemoveEntity(id)
+			world.Publish()
+		}
+	})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no entity %d", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StatsRequest sets the given stats, leaving the others as they are
+type StatsRequest struct {
+	Hunger    *float64 `json:"hunger,omitempty"`
+	Thirst    *float64 `json:"thirst,omitempty"`
+	Tiredness *float64 `json:"tiredness,omitempty"`
+}
+
+func (s *Server) handleEditStats(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	id, ok := entityID(w, r)
+	if !ok {
+		return
+	}
+	var req StatsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	for _, stat := range []struct {
+		name string
+		v    *float64
+	}{{"hunger", req.Hunger}, {"thirst", req.Thirst}, {"tiredness", req.Tiredness}} {
+		if stat.v != nil && (*stat.v < 0 || *stat.v > 100) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s must be between 0 and 100", stat.name))
+			return
+		}
+	}
+
+	var entity game.EntitySnapshot
+	var found bool
+	err := runner.Do(func(world *game.World) {
+		stats := world.Stats.Get(id)
+		if found = stats != nil; !found {
+			return
+		}
+		for _, set := range []struct {
+			v    *float64
+			stat *float64
+		}{{req.Hunger, &stats.Hunger}, {req.Thirst, &stats.Thirst}, {req.Tiredness, &stats.Tiredness}} {
+			if set.v != nil {
+				*set.stat = *set.v
+			}
+		}
+		world.Publish()
+		entity, _ = world.Entity(id)
+	})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no entity %d with stats", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, entity)
+}
+
+// savePath returns the file of the save named by the query, world-<id> by
+// default
+func (s *Server) savePath(r *http.Request, runner *Runner) (string, error) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = fmt.Sprintf("world-%d", runner.ID)
+	}
+	if !saveName.MatchString(name) {
+		return "", errors.New("save name may only hold letters, digits, - and _")
+	}
+	return filepath.Join(s.SaveDir, name+".json"), nil
+}
+
+// handleSave writes the world to the save directory
+func (s *Server) handleSave(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	path, err := s.savePath(r, runner)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var save *game.Save
+	var saveErr error
+	if err := runner.Do(func(world *game.World) { save, saveErr = world.Save() }); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if saveErr == nil {
+		saveErr = writeSave(path, save)
+	}
+	if saveErr != nil {
+		writeError(w, http.StatusInternalServerError, saveErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"path": path, "tick": save.Tick})
+}
+
+// writeSave writes through a temporary file so a crash never leaves half a
+// save behind
+func writeSave(path string, save *game.Save) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// handleLoad replaces the world with a save from the save directory
+func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	path, err := s.savePath(r, runner)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no save %s", filepath.Base(path)))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var save game.Save
+	if err := json.Unmarshal(data, &save); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %v", game.ErrBadSave, err))
+		return
+	}
+	var loadErr error
+	if err := runner.Do(func(world *game.World) { loadErr = world.Load(&save) }); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if loadErr != nil {
+		writeError(w, http.StatusBadRequest, loadErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, runner.Info())
+}
+
+// decodeBody decodes a JSON request body, writing an error when it fails
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
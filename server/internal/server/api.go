@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xSaCh/animalia/internal/game"
+	"github.com/xSaCh/animalia/internal/server/transport"
+)
+
+// streamInterval is how often world streams check for a new snapshot
+const streamInterval = 100 * time.Millisecond
+
+// WorldInfo summarizes a hosted world
+type WorldInfo struct {
+	ID       int     `json:"id"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	TPS      int     `json:"tps"`
+	Seed     uint64  `json:"seed"`
+	Tick     uint    `json:"tick"`
+	Paused   bool    `json:"paused"`
+	Entities int     `json:"entities"`
+}
+
+// Info summarizes the world as of its last snapshot
+func (r *Runner) Info() WorldInfo {
+	snap := r.World.Snapshot()
+	return WorldInfo{
+		ID:       r.ID,
+		Width:    snap.Width,
+		Height:   snap.Height,
+		TPS:      snap.Config.TPS,
+		Seed:     snap.Config.Seed,
+		Tick:     snap.Tick,
+		Paused:   r.Paused(),
+		Entities: len(snap.Entities),
+	}
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /worlds", s.handleListWorlds)
+	s.mux.HandleFunc("POST /worlds", s.handleCreateWorld)
+	s.mux.HandleFunc("GET /worlds/{id}", s.withWorld(s.handleGetWorld))
+	s.mux.HandleFunc("DELETE /worlds/{id}", s.withWorld(s.handleDeleteWorld))
+	s.mux.HandleFunc("POST /worlds/{id}/pause", s.withWorld(s.handlePause))
+	s.mux.HandleFunc("POST /worlds/{id}/resume", s.withWorld(s.handleResume))
+	s.mux.HandleFunc("GET /worlds/{id}/ws", s.withWorld(s.handleStream))
+}
+
+// withWorld resolves the {id} of the path before calling h
+func (s *Server) withWorld(h func(http.ResponseWriter, *http.Request, *Runner)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("world id must be a number"))
+			return
+		}
+		runner, err := s.World(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		h(w, r, runner)
+	}
+}
+
+func (s *Server) handleListWorlds(w http.ResponseWriter, r *http.Request) {
+	infos := []WorldInfo{}
+	for _, runner := range s.Worlds() {
+		infos = append(infos, runner.Info())
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleCreateWorld(w http.ResponseWriter, r *http.Request) {
+	var req WorldRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	runner, err := s.CreateWorld(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, runner.Info())
+}
+
+func (s *Server) handleGetWorld(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	writeJSON(w, http.StatusOK, runner.Info())
+}
+
+func (s *Server) handleDeleteWorld(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	if err := s.DeleteWorld(runner.ID); err != nil {
+		writeError(w, http.StatusNotFound, err) // Deleted by a concurrent request
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	runner.Pause()
+	writeJSON(w, http.StatusOK, runner.Info())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	runner.Resume()
+	writeJSON(w, http.StatusOK, runner.Info())
+}
+
+// handleStream sends the world's snapshot over a WebSocket whenever a new
+// tick has been published, until the client leaves or the world is deleted.
+// Clients may narrow it to an area with a ClientMessage.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request, runner *Runner) {
+	conn, err := transport.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	conn.IdleTimeout, conn.WriteTimeout = s.IdleTimeout, writeTimeout
+	sess, ok := s.openSession(conn)
+	if !ok {
+		conn.CloseWith(transport.CloseGoingAway)
+		return
+	}
+	defer s.closeSession(sess)
+
+	// Reading also answers the client's pings, hears its pongs and notices
+	// when it goes away or goes quiet
+	gone := make(chan struct{})
+	received := make(chan []byte)
+	go func() {
+		defer close(gone)
+		for {
+			data, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			select {
+			case received <- data:
+			case <-sess.done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+	in := newInterest()
+	var sent *game.Snapshot
+	for {
+		select {
+		case <-gone:
+			return
+		case <-sess.done:
+			return
+		case <-runner.Done():
+			return
+		case data := <-received:
+			var msg ClientMessage
+			err := json.Unmarshal(data, &msg)
+			if err == nil {
+				err = in.update(msg, runner.World.Snapshot())
+			}
+			if err != nil {
+				reply, _ := json.Marshal(ErrorMessage{Type: "error", Error: err.Error()})
+				if sess.send(reply) != nil {
+					return
+				}
+				continue
+			}
+			sent = nil // Send the new area without waiting for a tick
+			continue
+		case <-ticker.C:
+		}
+		snap := runner.World.Snapshot()
+		if snap == sent {
+			continue // Paused, nothing new
+		}
+		// The snapshot comes last, after the messages that go with it
+		var batch [][]byte
+		for _, msg := range in.messages(snap) {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				log.Printf("world %d: encode snapshot: %v", runner.ID, err)
+				return
+			}
+			batch = append(batch, data)
+		}
+		if err := sess.sendSnapshot(batch[:len(batch)-1], batch[len(batch)-1]); err != nil {
+			log.Printf("world %d: stream: %v", runner.ID, err)
+			conn.CloseWith(transport.CloseGoingAway) // Don't wait for it to catch up
+			return
+		}
+		sent = snap
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
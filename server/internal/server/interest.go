@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game"
+)
+
+const (
+	// interestMargin is how far beyond the area a client asked for entities
+	// are still streamed, so they are already there when the camera pans
+	interestMargin = 8
+
+	// followRadius is the half size of the area around a followed entity
+	// when the client gives none
+	followRadius = 24
+)
+
+// ClientMessage chooses which part of the world a stream sends. Until the
+// first one arrives the stream sends every entity and chunk. A "chunks"
+// message asks for chunks again, such as after the client dropped them, or
+// for chunks outside the area. They come with the next snapshot.
+//
+//	{"type": "viewport", "x": 10, "y": 20, "width": 40, "height": 30}
+//	{"type": "follow", "id": 7, "radius": 20}
+//	{"type": "all"}
+//	{"type": "chunks", "chunks": [{"x": 0, "y": 1}]}
+type ClientMessage struct {
+	Type   string         `json:"type"`
+	X      float64        `json:"x,omitempty"`
+	Y      float64        `json:"y,omitempty"`
+	Width  float64        `json:"width,omitempty"`
+	Height float64        `json:"height,omitempty"`
+	ID     int            `json:"id,omitempty"`
+	Radius float64        `json:"radius,omitempty"`
+	Chunks []game.ChunkID `json:"chunks,omitempty"`
+}
+
+// MembershipMessage tells a client which entities came into its area
+// ("enter") or went out of it or died ("leave"). It is sent right before
+// the snapshot that first has, or first lacks, them.
+type MembershipMessage struct {
+	Type string `json:"type"`
+	IDs  []int  `json:"ids"`
+}
+
+// ChunksMessage carries map chunks the client lacks or holds an older
+// version of, sent before the snapshot whose map they belong to. Snapshots
+// sent over streams leave out the grids and keep only the map version.
+type ChunksMessage struct {
+	Type   string           `json:"type"` // Always "chunks"
+	Size   int              `json:"size"` // Cells along a chunk's side
+	Chunks []*game.MapChunk `json:"chunks"`
+}
+
+// ErrorMessage reports a client message the stream could not use
+type ErrorMessage struct {
+	Type  string `json:"type"` // Always "error"
+	Error string `json:"error"`
+}
+
+// interest is what one stream client wants to see and what it was sent
+type interest struct {
+	all    bool      // Whole snapshots, the default
+	area   game.Area // Viewport, or the last area around the followed entity
+	follow int       // Followed entity, 0 for none
+	radius float64   // Half size of the area around the followed entity
+
+	known     map[int]bool          // Entities in the last snapshot sent
+	chunks    map[game.ChunkID]uint // Versions of the chunks sent
+	requested []game.ChunkID        // Chunks asked for, sent with the next snapshot
+}
+
+func newInterest() *interest {
+	return &interest{all: true, known: map[int]bool{}, chunks: map[game.ChunkID]uint{}}
+}
+
+// update applies a client message, checking the entity or chunks it names
+// against the latest snapshot
+func (in *interest) update(msg ClientMessage, snap *game.Snapshot) error {
+	switch msg.Type {
+	case "all":
+		in.all, in.follow = true, 0
+	case "viewport":
+		if msg.Width <= 0 || msg.Height <= 0 {
+			return errors.New("viewport needs a positive width and height")
+		}
+		in.all, in.follow = false, 0
+		in.area = game.Area{
+			Min: common.Vector2D{X: msg.X, Y: msg.Y},
+			Max: common.Vector2D{X: msg.X + msg.Width, Y: msg.Y + msg.Height},
+		}
+	case "follow":
+		if msg.ID <= 0 {
+			return errors.New("follow needs an entity id")
+		}
+		e, ok := snap.FindEntity(msg.ID)
+		if !ok {
+			return fmt.Errorf("no entity %d to follow", msg.ID)
+		}
+		// Once the entity is gone the area stays where it was last seen
+		in.all, in.follow, in.radius = false, msg.ID, msg.Radius
+		if in.radius <= 0 {
+			in.radius = followRadius
+		}
+		in.area = game.AreaAround(e.Position, in.radius)
+	case "chunks":
+		for _, id := range msg.Chunks {
+			if _, ok := snap.ChunkVersion(id); !ok {
+				return fmt.Errorf("no chunk (%d, %d)", id.X, id.Y)
+			}
+		}
+		for _, id := range msg.Chunks {
+			delete(in.chunks, id)
+			in.requested = append(in.requested, id)
+		}
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	return nil
+}
+
+// messages returns what to send the client for a snapshot, in order with
+// the snapshot last
+func (in *interest) messages(snap *game.Snapshot) []any {
+	if in.follow != 0 {
+		if e, ok := snap.FindEntity(in.follow); ok {
+			in.area = game.AreaAround(e.Position, in.radius)
+		}
+	}
+	area := in.area.Grow(interestMargin)
+
+	var out []any
+	wanted := snap.Chunks()
+	if !in.all {
+		wanted = append(snap.ChunksIn(area), in.requested...)
+	}
+	in.requested = nil
+	if chunks := in.staleChunks(snap.MapSnapshot, wanted); len(chunks) > 0 {
+		out = append(out, ChunksMessage{Type: "chunks", Size: game.ChunkSize, Chunks: chunks})
+	}
+
+	view := *snap
+	view.MapSnapshot = &game.MapSnapshot{Version: snap.Version}
+	if in.all {
+		clear(in.known)
+		for _, e := range snap.Entities {
+			in.known[e.ID] = true
+		}
+		return append(out, &view)
+	}
+	view.Entities = snap.EntitiesIn(area)
+
+	visible := make(map[int]bool, len(view.Entities))
+	var entered, left []int
+	for _, e := range view.Entities {
+		visible[e.ID] = true
+		if !in.known[e.ID] {
+			entered = append(entered, e.ID)
+		}
+	}
+	for id := range in.known {
+		if !visible[id] {
+			left = append(left, id)
+		}
+	}
+
+	// Keep world events and those of entities the client sees or just lost
+	view.Events = []game.Event{}
+	for _, ev := range snap.Events {
+		if ev.EntityID == 0 || visible[ev.EntityID] || in.known[ev.EntityID] {
+			view.Events = append(view.Events, ev)
+		}
+	}
+	in.known = visible
+
+	if len(entered) > 0 {
+		out = append(out, MembershipMessage{Type: "enter", IDs: entered})
+	}
+	if len(left) > 0 {
+		slices.Sort(left)
+		out = append(out, MembershipMessage{Type: "leave", IDs: left})
+	}
+	return append(out, &view)
+}
+
+// staleChunks returns the wanted chunks the client lacks and every chunk it
+// holds that changed since it was sent. Chunks that left the area stay with
+// the client, so it only hears about them again when they change.
+func (in *interest) staleChunks(m *game.MapSnapshot, wanted []game.ChunkID) []*game.MapChunk {
+	var stale []*game.MapChunk
+	send := func(id game.ChunkID) {
+		c, ok := m.Chunk(id)
+		if !ok {
+			delete(in.chunks, id) // Gone after loading a smaller map
+			return
+		}
+		if sent, ok := in.chunks[id]; ok && sent == c.Version {
+			return
+		}
+		in.chunks[id] = c.Version
+		stale = append(stale, c)
+	}
+	for _, id := range wanted {
+		send(id)
+	}
+	for id, version := range in.chunks {
+		if current, ok := m.ChunkVersion(id); !ok || current != version {
+			send(id)
+		}
+	}
+	slices.SortFunc(stale, func(a, b *game.MapChunk) int {
+		if a.Y != b.Y {
+			return a.Y - b.Y
+		}
+		return a.X - b.X
+	})
+	return stale
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/xSaCh/animalia/internal/game"
+)
+
+// ErrStopped is returned for commands sent to a deleted world
+var ErrStopped = errors.New("world stopped")
+
+// Runner ticks one world on its own goroutine at the world's TPS. Only that
+// goroutine touches the world: everyone else reads its snapshots or sends
+// commands through Do.
+type Runner struct {
+	ID    int
+	World *game.World
+
+	paused   atomic.Bool
+	commands chan func()
+	stop     chan struct{}
+	done     chan struct{} // Closed once the loop has exited
+}
+
+func newRunner(id int, world *game.World) *Runner {
+	world.ID = id
+	world.Publish()
+	return &Runner{
+		ID:       id,
+		World:    world,
+		commands: make(chan func()),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start launches the tick loop
+func (r *Runner) start() {
+	go r.run()
+}
+
+func (r *Runner) run() {
+	defer close(r.done)
+	tps := r.World.Config.TPS
+	ticker := time.NewTicker(time.Second / time.Duration(tps))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case cmd := <-r.commands:
+			cmd()
+			if r.World.Config.TPS != tps {
+				tps = r.World.Config.TPS // Loaded a save running at another rate
+				ticker.Reset(time.Second / time.Duration(tps))
+			}
+		case <-ticker.C:
+			if !r.paused.Load() {
+				r.World.Tick()
+			}
+		}
+	}
+}
+
+// Do runs fn on the tick goroutine between two ticks, also while paused,
+// and waits for it. Commands that change the world should end with
+// World.Publish so observers see the change before the next tick.
+func (r *Runner) Do(fn func(w *game.World)) error {
+	finished := make(chan struct{})
+	cmd := func() {
+		defer close(finished)
+		fn(r.World)
+	}
+	select {
+	case r.commands <- cmd:
+		<-finished
+		return nil
+	case <-r.done:
+		return ErrStopped
+	}
+}
+
+// Pause stops the world between ticks until Resume
+func (r *Runner) Pause() { r.paused.Store(true) }
+
+// Resume continues a paused world
+func (r *Runner) Resume() { r.paused.Store(false) }
+
+// Paused reports whether the world is paused
+func (r *Runner) Paused() bool { return r.paused.Load() }
+
+// Stop ends the tick loop and waits for the tick in progress to finish
+func (r *Runner) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Done is closed once the runner has stopped
+func (r *Runner) Done() <-chan struct{} { return r.done }
@@ -1,14 +1,242 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xSaCh/animalia/internal/common"
+	"github.com/xSaCh/animalia/internal/game"
+	"github.com/xSaCh/animalia/internal/server/transport"
 )
 
-func StartServer(port int) {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Hello, world!"))
+const (
+	defaultTPS       = 20
+	defaultWorldSize = 120
+	minWorldSize     = 16
+	maxWorldSize     = 1000
+
+	// shutdownTimeout bounds how long StartServer waits for streams to
+	// flush on SIGINT or SIGTERM
+	shutdownTimeout = 5 * time.Second
+)
+
+var ErrNoWorld = errors.New("no such world")
+
+// Server hosts any number of independent worlds, each ticked by its own
+// Runner, and serves the HTTP API that manages and streams them
+type Server struct {
+	SaveDir      string        // Where the admin API writes and reads saves
+	PingInterval time.Duration // How often streams ping their client
+	IdleTimeout  time.Duration // Streams close after hearing nothing from the client this long
+
+	mux *http.ServeMux
+
+	mu           sync.Mutex
+	worlds       map[int]*Runner
+	nextID       int
+	sessions     map[*session]bool
+	shuttingDown bool
+}
+
+// New returns a server hosting no worlds
+func New() *Server {
+	s := &Server{
+		SaveDir:      "saves",
+		PingInterval: 10 * time.Second,
+		IdleTimeout:  30 * time.Second,
+		mux:          http.NewServeMux(),
+		worlds:       map[int]*Runner{},
+		sessions:     map[*session]bool{},
+	}
+	s.routes()
+	s.adminRoutes()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// WorldRequest describes a world to create. Config is decoded on top of the
+// default config like a config file, Spawn lists how many of each species
+// to start with.
+type WorldRequest struct {
+	Size   int                       `json:"size"`
+	Config json.RawMessage           `json:"config,omitempty"`
+	Spawn  map[common.EntityType]int `json:"spawn,omitempty"`
+}
+
+// CreateWorld generates a world, populates it and starts ticking it
+func (s *Server) CreateWorld(req WorldRequest) (*Runner, error) {
+	if req.Size == 0 {
+		req.Size = defaultWorldSize
+	}
+	if req.Size < minWorldSize || req.Size > maxWorldSize {
+		return nil, fmt.Errorf("size must be between %d and %d, got %d", minWorldSize, maxWorldSize, req.Size)
+	}
+	cfg := game.DefaultConfig(defaultTPS)
+	if len(req.Config) > 0 {
+		var err error
+		if cfg, err = game.ParseConfig(req.Config, defaultTPS); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	types := make([]common.EntityType, 0, len(req.Spawn))
+	for t, n := range req.Spawn {
+		if _, ok := game.NewBehaviorTree(t); !ok {
+			return nil, fmt.Errorf("spawn: unknown entity type %q", t)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("spawn: negative count for %s", t)
+		}
+		types = append(types, t)
+	}
+	slices.Sort(types) // Same seed, same world
+
+	world := game.NewWorldWithConfig(req.Size, cfg)
+	for _, t := range types {
+		for range req.Spawn[t] {
+			pos, err := world.GetRandomWalkablePosition()
+			if err != nil {
+				return nil, fmt.Errorf("spawn %s: %w", t, err)
+			}
+			world.Spawn(t, pos)
+		}
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	r := newRunner(s.nextID, world)
+	s.worlds[r.ID] = r
+	s.mu.Unlock()
+	r.start()
+	return r, nil
+}
+
+// World returns the runner of a hosted world
+func (s *Server) World(id int) (*Runner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.worlds[id]
+	if !ok {
+		return nil, fmt.Errorf("world %d: %w", id, ErrNoWorld)
+	}
+	return r, nil
+}
+
+// Worlds returns every hosted world, sorted by ID
+func (s *Server) Worlds() []*Runner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runners := make([]*Runner, 0, len(s.worlds))
+	for _, r := range s.worlds {
+		runners = append(runners, r)
+	}
+	slices.SortFunc(runners, func(a, b *Runner) int { return a.ID - b.ID })
+	return runners
+}
+
+// DeleteWorld stops a world and forgets it. Streams of the world end.
+func (s *Server) DeleteWorld(id int) error {
+	s.mu.Lock()
+	r, ok := s.worlds[id]
+	delete(s.worlds, id)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("world %d: %w", id, ErrNoWorld)
+	}
+	r.Stop()
+	return nil
+}
+
+// openSession starts a session for a stream client, false once the server
+// is shutting down
+func (s *Server) openSession(conn transport.Transport) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown {
+		return nil, false
+	}
+	sess := newSession(conn, s.PingInterval)
+	s.sessions[sess] = true
+	return sess, true
+}
+
+// closeSession flushes and closes a session and waits for it
+func (s *Server) closeSession(sess *session) {
+	sess.close(transport.CloseNormal)
+	<-sess.done
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+}
+
+// Shutdown flushes and closes every stream, telling clients the server is
+// going away, then stops every world. Streams that haven't flushed by the
+// time ctx ends are cut off.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	var err error
+	for _, sess := range sessions {
+		sess.close(transport.CloseGoingAway)
+	}
+	for _, sess := range sessions {
+		select {
+		case <-sess.done:
+		case <-ctx.Done():
+			sess.conn.Close()
+			err = ctx.Err()
+		}
+	}
+	for _, r := range s.Worlds() {
+		s.DeleteWorld(r.ID)
+	}
+	return err
+}
+
+// StartServer serves the API on port with one default world, populated
+// like the standalone simulation, until SIGINT or SIGTERM
+func StartServer(port int) error {
+	s := New()
+	_, err := s.CreateWorld(WorldRequest{
+		Spawn: map[common.EntityType]int{common.EntityTypeGoat: 10, common.EntityTypeWolf: 2},
 	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hs := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: s}
+	served := make(chan error, 1)
+	go func() { served <- hs.ListenAndServe() }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
 
-	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+	// Streams are hijacked connections, hs.Shutdown only stops new ones
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := hs.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.Shutdown(ctx)
 }
@@ -0,0 +1,509 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xSaCh/animalia/internal/game"
+	"github.com/xSaCh/animalia/internal/server/transport"
+)
+
+func request(t *testing.T, method, url, body string, want int, out any) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, url, res.StatusCode, want, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+	}
+}
+
+// dialWorld opens a WebSocket to a world's stream
+func dialWorld(t *testing.T, ts *httptest.Server, id string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest("GET", ts.URL+"/worlds/"+id+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: status %d", res.StatusCode)
+	}
+	if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: accept %q", got)
+	}
+	return conn, r
+}
+
+// readFrame reads one unmasked server frame
+func readFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// readControl skips snapshots until the next control frame
+func readControl(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	for {
+		if op, payload := readFrame(t, r); op != 0x1 {
+			return op, payload
+		}
+	}
+}
+
+// readMessage reads the next text message, its type is empty for snapshots
+func readMessage(t *testing.T, r *bufio.Reader) (string, []byte) {
+	t.Helper()
+	for {
+		if op, payload := readFrame(t, r); op == 0x1 {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				t.Fatal(err)
+			}
+			return msg.Type, payload
+		}
+	}
+}
+
+// readSnapshot skips other messages until the next snapshot
+func readSnapshot(t *testing.T, r *bufio.Reader) game.Snapshot {
+	t.Helper()
+	for {
+		if typ, payload := readMessage(t, r); typ == "" {
+			var snap game.Snapshot
+			if err := json.Unmarshal(payload, &snap); err != nil {
+				t.Fatal(err)
+			}
+			return snap
+		}
+	}
+}
+
+// readError skips snapshots up to the next message, which must be an error
+func readError(t *testing.T, r *bufio.Reader) {
+	t.Helper()
+	typ, payload := readMessage(t, r)
+	for typ == "" {
+		typ, payload = readMessage(t, r)
+	}
+	var msg ErrorMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != "error" {
+		t.Fatalf("got %.80s, want an error", payload)
+	}
+}
+
+// writeFrame writes a masked client frame
+func writeFrame(t *testing.T, conn net.Conn, op byte, payload []byte) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorlds(t *testing.T) {
+	ts := httptest.NewServer(New())
+	defer ts.Close()
+
+	var a, b WorldInfo
+	request(t, "POST", ts.URL+"/worlds", `{"size": 32, "config": {"seed": 7, "tps": 50}, "spawn": {"goat": 3}}`, http.StatusCreated, &a)
+	request(t, "POST", ts.URL+"/worlds", `{"size": 40}`, http.StatusCreated, &b)
+	if a.ID == b.ID || a.Seed != 7 || a.TPS != 50 || a.Width != 32 || b.Width != 40 {
+		t.Fatalf("created %+v and %+v", a, b)
+	}
+	var cfg game.Config
+	request(t, "GET", ts.URL+"/worlds/1/config", "", http.StatusOK, &cfg)
+	if cfg.TicksPerDay != 240*50 {
+		t.Fatalf("ticks per day %d at 50 tps, want %d", cfg.TicksPerDay, 240*50)
+	}
+	request(t, "POST", ts.URL+"/worlds", `{"spawn": {"dragon": 1}}`, http.StatusBadRequest, nil)
+	request(t, "POST", ts.URL+"/worlds", `{"config": {"tps": 0}}`, http.StatusBadRequest, nil)
+	request(t, "POST", ts.URL+"/worlds", `{"config": {"tps": 2000000000}}`, http.StatusBadRequest, nil)
+
+	var list []WorldInfo
+	request(t, "GET", ts.URL+"/worlds", "", http.StatusOK, &list)
+	if len(list) != 2 || list[0].ID != a.ID || list[1].ID != b.ID {
+		t.Fatalf("listed %+v", list)
+	}
+
+	var paused WorldInfo
+	request(t, "POST", ts.URL+"/worlds/2/pause", "", http.StatusOK, &paused)
+	if !paused.Paused {
+		t.Fatal("world 2 did not pause")
+	}
+
+	// The stream sends snapshots of its own world only, and pings get a pong
+	conn, r := dialWorld(t, ts, "1")
+	snap := readSnapshot(t, r)
+	if snap.ID != a.ID || len(snap.Entities) != 3 {
+		t.Fatalf("streamed world %d with %d entities", snap.ID, len(snap.Entities))
+	}
+	writeFrame(t, conn, 0x9, []byte("hi"))
+	if op, payload := readControl(t, r); op != 0xA || !bytes.Equal(payload, []byte("hi")) {
+		t.Fatalf("ping answered with op %d %q", op, payload)
+	}
+
+	// Deleting the world closes its streams
+	request(t, "DELETE", ts.URL+"/worlds/1", "", http.StatusNoContent, nil)
+	if op, _ := readControl(t, r); op != 0x8 {
+		t.Fatalf("stream sent op %d after delete, want close", op)
+	}
+	request(t, "GET", ts.URL+"/worlds/1", "", http.StatusNotFound, nil)
+	request(t, "GET", ts.URL+"/worlds/x", "", http.StatusBadRequest, nil)
+	request(t, "DELETE", ts.URL+"/worlds/2", "", http.StatusNoContent, nil)
+}
+
+func TestAdmin(t *testing.T) {
+	s := New()
+	s.SaveDir = t.TempDir()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	var info WorldInfo
+	request(t, "POST", ts.URL+"/worlds", `{"size": 32, "config": {"seed": 3}, "spawn": {"goat": 2}}`, http.StatusCreated, &info)
+	request(t, "POST", ts.URL+"/worlds/1/pause", "", http.StatusOK, nil)
+	base := ts.URL + "/worlds/1"
+
+	var config game.Config
+	request(t, "GET", base+"/config", "", http.StatusOK, &config)
+	if config.Seed != 3 {
+		t.Fatalf("config seed %d, want 3", config.Seed)
+	}
+
+	var wolf game.EntitySnapshot
+	request(t, "POST", base+"/entities", `{"type": "wolf"}`, http.StatusCreated, &wolf)
+	request(t, "POST", base+"/entities", `{"type": "dragon"}`, http.StatusBadRequest, nil)
+	var wolves []game.EntitySnapshot
+	request(t, "GET", base+"/entities?type=wolf", "", http.StatusOK, &wolves)
+	if len(wolves) != 1 || wolves[0].ID != wolf.ID {
+		t.Fatalf("listed wolves %+v", wolves)
+	}
+
+	eid := base + "/entities/" + strconv.Itoa(wolf.ID)
+	var edited game.EntitySnapshot
+	request(t, "PATCH", eid+"/stats", `{"hunger": 90}`, http.StatusOK, &edited)
+	if edited.Stats.Hunger != 90 {
+		t.Fatalf("hunger %g after edit, want 90", edited.Stats.Hunger)
+	}
+	request(t, "PATCH", eid+"/stats", `{"thirst": 101}`, http.StatusBadRequest, nil)
+	var hungry []game.EntitySnapshot
+	request(t, "GET", base+"/entities?hunger_min=80", "", http.StatusOK, &hungry)
+	if len(hungry) != 1 || hungry[0].ID != wolf.ID {
+		t.Fatalf("listed hungry %+v", hungry)
+	}
+
+	var detail EntityDetail
+	request(t, "GET", eid, "", http.StatusOK, &detail)
+	if detail.Brain == nil || len(detail.Brain.Nodes) == 0 {
+		t.Fatal("entity has no brain state")
+	}
+	if detail.Brain.Traced {
+		t.Fatal("inspecting the entity started tracing it")
+	}
+	request(t, "POST", eid+"/trace", "", http.StatusOK, &detail)
+	if !detail.Brain.Traced {
+		t.Fatal("entity is not traced after POST trace")
+	}
+	request(t, "DELETE", eid+"/trace", "", http.StatusOK, &detail)
+	if detail.Brain.Traced {
+		t.Fatal("entity is still traced after DELETE trace")
+	}
+	request(t, "POST", base+"/entities/999/trace", "", http.StatusNotFound, nil)
+
+	// Killing the wolf and loading the save brings it back
+	request(t, "POST", base+"/save?name=before", "", http.StatusOK, nil)
+	request(t, "DELETE", eid, "", http.StatusNoContent, nil)
+	request(t, "GET", eid, "", http.StatusNotFound, nil)
+	request(t, "POST", base+"/load?name=before", "", http.StatusOK, nil)
+	request(t, "GET", eid, "", http.StatusOK, nil)
+	request(t, "POST", base+"/load?name=missing", "", http.StatusNotFound, nil)
+	request(t, "POST", base+"/save?name=../x", "", http.StatusBadRequest, nil)
+}
+
+func TestStreamInterest(t *testing.T) {
+	ts := httptest.NewServer(New())
+	defer ts.Close()
+	request(t, "POST", ts.URL+"/worlds", `{"size": 64, "config": {"seed": 5, "tps": 1}, "spawn": {"goat": 30}}`, http.StatusCreated, nil)
+	request(t, "POST", ts.URL+"/worlds/1/pause", "", http.StatusOK, nil)
+
+	// The map comes first, as chunks covering all of it
+	conn, r := dialWorld(t, ts, "1")
+	typ, payload := readMessage(t, r)
+	var chunks ChunksMessage
+	if err := json.Unmarshal(payload, &chunks); err != nil || typ != "chunks" || len(chunks.Chunks) != 4 {
+		t.Fatalf("got %.80s, want the map's 4 chunks", payload)
+	}
+	for _, c := range chunks.Chunks {
+		cells := 0
+		for _, run := range c.Walkable {
+			cells += run
+		}
+		if cells != c.Width*c.Height || c.Width != game.ChunkSize {
+			t.Fatalf("chunk %+v has %d cells in its runs", c.ChunkID, cells)
+		}
+	}
+	full := readSnapshot(t, r)
+	if full.NavigationGrid != nil {
+		t.Fatal("stream sent the whole grid")
+	}
+
+	// A small viewport around the first goat streams its neighbours only
+	center := full.Entities[0].Position
+	area := game.AreaAround(center, 1).Grow(interestMargin)
+	want := map[int]bool{}
+	for _, e := range full.Entities {
+		if area.Contains(e.Position) {
+			want[e.ID] = true
+		}
+	}
+	viewport, _ := json.Marshal(ClientMessage{Type: "viewport", X: center.X - 1, Y: center.Y - 1, Width: 2, Height: 2})
+	writeFrame(t, conn, 0x1, viewport)
+
+	var left MembershipMessage
+	_, payload = readMessage(t, r)
+	if err := json.Unmarshal(payload, &left); err != nil || left.Type != "leave" || len(left.IDs) != len(full.Entities)-len(want) {
+		t.Fatalf("got %s, want %d entities leaving", payload, len(full.Entities)-len(want))
+	}
+	var view game.Snapshot
+	typ, payload = readMessage(t, r)
+	if err := json.Unmarshal(payload, &view); err != nil || typ != "" {
+		t.Fatalf("got %.80s, want a snapshot", payload)
+	}
+	if len(view.Entities) != len(want) || view.Version != full.Version {
+		t.Fatalf("view has %d entities, want %d", len(view.Entities), len(want))
+	}
+	for _, e := range view.Entities {
+		if !want[e.ID] {
+			t.Fatalf("entity %d at %v is outside the viewport", e.ID, e.Position)
+		}
+	}
+
+	// Chunks outside the viewport come when asked for
+	writeFrame(t, conn, 0x1, []byte(`{"type": "chunks", "chunks": [{"x": 0, "y": 0}, {"x": 1, "y": 0}, {"x": 0, "y": 1}, {"x": 1, "y": 1}]}`))
+	typ, payload = readMessage(t, r)
+	if err := json.Unmarshal(payload, &chunks); err != nil || typ != "chunks" || len(chunks.Chunks) != 4 {
+		t.Fatalf("got %.80s, want the 4 chunks asked for", payload)
+	}
+	readSnapshot(t, r)
+	writeFrame(t, conn, 0x1, []byte(`{"type": "chunks", "chunks": [{"x": 9, "y": 0}]}`))
+	readError(t, r)
+
+	// Following an entity streams the area around it, following none fails
+	writeFrame(t, conn, 0x1, []byte(`{"type": "follow", "id": 999}`))
+	readError(t, r)
+	followed := full.Entities[len(full.Entities)-1]
+	follow, _ := json.Marshal(ClientMessage{Type: "follow", ID: followed.ID, Radius: 2})
+	writeFrame(t, conn, 0x1, follow)
+	view = readSnapshot(t, r)
+	if _, ok := view.FindEntity(followed.ID); !ok || len(view.Entities) == len(full.Entities) {
+		t.Fatalf("following %d streamed %d entities without it", followed.ID, len(view.Entities))
+	}
+
+	// Going back to everything brings the others back in a whole snapshot
+	writeFrame(t, conn, 0x1, []byte(`{"type": "all"}`))
+	if view = readSnapshot(t, r); len(view.Entities) != len(full.Entities) {
+		t.Fatalf("got %d entities after asking for all, want %d", len(view.Entities), len(full.Entities))
+	}
+
+	// Chunks the client asks for again are resent alone
+	writeFrame(t, conn, 0x1, []byte(`{"type": "chunks", "chunks": [{"x": 1, "y": 0}]}`))
+	typ, payload = readMessage(t, r)
+	if err := json.Unmarshal(payload, &chunks); err != nil || typ != "chunks" || len(chunks.Chunks) != 1 || chunks.Chunks[0].X != 1 {
+		t.Fatalf("got %.80s, want chunk (1, 0)", payload)
+	}
+
+	writeFrame(t, conn, 0x1, []byte(`{"type": "zoom"}`))
+	readError(t, r)
+}
+
+// heldTransport holds every Send until release is closed
+type heldTransport struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    []string
+}
+
+func (t *heldTransport) Send(msg []byte) error {
+	<-t.release
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, string(msg))
+	return nil
+}
+
+func (t *heldTransport) Receive() ([]byte, error) { select {} }
+func (t *heldTransport) Ping() error              { return nil }
+func (t *heldTransport) Close() error             { return nil }
+
+func TestSessionBackpressure(t *testing.T) {
+	conn := &heldTransport{release: make(chan struct{})}
+	sess := newSession(conn, time.Hour)
+
+	// Snapshots replace each other, their messages and replies queue up to
+	// a limit
+	var queued []string
+	sess.sendSnapshot(nil, []byte("snapshot 1"))
+	for i := 0; ; i++ {
+		msg := fmt.Sprintf("message %d", i)
+		if err := sess.sendSnapshot([][]byte{[]byte(msg)}, []byte(fmt.Sprintf("snapshot %d", i+2))); err != nil {
+			if i < sessionQueue {
+				t.Fatalf("queue full after %d messages, want %d", i, sessionQueue)
+			}
+			break
+		}
+		queued = append(queued, msg)
+	}
+	last := fmt.Sprintf("snapshot %d", len(queued)+1)
+	if err := sess.send([]byte("reply")); err == nil {
+		t.Error("queued a reply past the limit")
+	}
+
+	close(conn.release)
+	sess.close(transport.CloseNormal)
+	<-sess.done
+
+	// The writer may have taken the first snapshot before any message
+	sent := conn.sent
+	if sent[0] == "snapshot 1" {
+		sent = sent[1:]
+	}
+	want := append(queued, last)
+	if !slices.Equal(sent, want) {
+		t.Fatalf("sent %q, want the messages in order and only %q", sent, last)
+	}
+}
+
+// A snapshot never goes out after the messages of a later one
+func TestSessionOrder(t *testing.T) {
+	conn := &heldTransport{release: make(chan struct{})}
+	close(conn.release)
+	sess := newSession(conn, time.Hour)
+	for i := 1; i <= 2000; i++ {
+		msg, snap := fmt.Sprintf("message %d", i), fmt.Sprintf("snapshot %d", i)
+		if sess.sendSnapshot([][]byte{[]byte(msg)}, []byte(snap)) != nil {
+			break
+		}
+	}
+	sess.close(transport.CloseNormal)
+	<-sess.done
+
+	lastMessage := 0
+	for _, msg := range conn.sent {
+		var n int
+		if _, err := fmt.Sscanf(msg, "message %d", &n); err == nil {
+			lastMessage = n
+		} else if _, err := fmt.Sscanf(msg, "snapshot %d", &n); err != nil || n != lastMessage {
+			t.Fatalf("sent %q after message %d", msg, lastMessage)
+		}
+	}
+}
+
+func TestStreamLifecycle(t *testing.T) {
+	s := New()
+	s.PingInterval, s.IdleTimeout = 20*time.Millisecond, 200*time.Millisecond
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	request(t, "POST", ts.URL+"/worlds", `{"size": 32}`, http.StatusCreated, nil)
+
+	// A client that never answers pings is closed once it has been quiet
+	// for the idle timeout
+	_, r := dialWorld(t, ts, "1")
+	pinged := false
+	for {
+		op, payload := readControl(t, r)
+		if op == 0x9 {
+			pinged = true
+			continue
+		}
+		if op != 0x8 || !pinged || binary.BigEndian.Uint16(payload) != transport.CloseGoingAway {
+			t.Fatalf("got op %d %v, pinged %t, want going away after pings", op, payload, pinged)
+		}
+		break
+	}
+
+	// Shutting down tells streams the server is going away and stops the
+	// worlds
+	_, r = dialWorld(t, ts, "1")
+	readSnapshot(t, r)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if op, payload := readControl(t, r); op == 0x8 {
+			if code := binary.BigEndian.Uint16(payload); code != transport.CloseGoingAway {
+				t.Fatalf("closed with %d, want going away", code)
+			}
+			break
+		}
+	}
+	if len(s.Worlds()) != 0 {
+		t.Fatal("worlds still running after shutdown")
+	}
+}
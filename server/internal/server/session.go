@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xSaCh/animalia/internal/server/transport"
+)
+
+const (
+	// sessionQueue bounds the messages other than snapshots waiting for a
+	// client. A client that falls this far behind is disconnected.
+	sessionQueue = 64
+
+	// writeTimeout bounds writing one message to a client
+	writeTimeout = 10 * time.Second
+)
+
+var errSlowClient = errors.New("client fell behind, send queue full")
+
+// closeCoder is implemented by transports that tell the client why they
+// close, such as WebSocketTransport
+type closeCoder interface {
+	CloseWith(code uint16) error
+}
+
+// session writes to one stream client from its own goroutine, so a slow
+// client holds up neither its stream nor the world. Snapshots replace each
+// other while they wait and only the latest goes out. The messages that
+// come with a snapshot, such as chunks, go out right before it, together
+// with those of the snapshots it replaced. Replies go out first. No message
+// is dropped: a client that lets too many pile up is disconnected.
+type session struct {
+	conn transport.Transport
+
+	mu       sync.Mutex
+	queue    [][]byte // Replies
+	batch    [][]byte // Messages that go before the snapshot
+	snapshot []byte   // Latest snapshot waiting, nil once sent
+
+	wake      chan struct{} // Something was queued
+	closing   chan struct{} // Closed by close
+	closeCode uint16
+	closeOnce sync.Once
+	done      chan struct{} // Closed once the writer has exited
+}
+
+// newSession starts writing to conn, pinging it every pingInterval
+func newSession(conn transport.Transport, pingInterval time.Duration) *session {
+	s := &session{
+		conn:    conn,
+		wake:    make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.write(pingInterval)
+	return s
+}
+
+// send queues a reply, failing when the client has fallen too far behind
+func (s *session) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue)+len(s.batch) >= sessionQueue {
+		return errSlowClient
+	}
+	s.queue = append(s.queue, msg)
+	s.notify()
+	return nil
+}
+
+// sendSnapshot queues a snapshot in place of any still waiting, preceded by
+// msgs. It fails like send when the client has fallen too far behind.
+func (s *session) sendSnapshot(msgs [][]byte, snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue)+len(s.batch)+len(msgs) > sessionQueue {
+		return errSlowClient
+	}
+	s.batch = append(s.batch, msgs...)
+	s.snapshot = snapshot
+	s.notify()
+	return nil
+}
+
+func (s *session) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default: // The writer is already due to look
+	}
+}
+
+// close flushes what is queued and then closes the connection with code,
+// without waiting. Only the first close counts.
+func (s *session) close(code uint16) {
+	s.closeOnce.Do(func() {
+		s.closeCode = code
+		close(s.closing)
+	})
+}
+
+func (s *session) write(pingInterval time.Duration) {
+	defer close(s.done)
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-s.closing:
+			if s.flush() != nil {
+				s.conn.Close()
+			} else if c, ok := s.conn.(closeCoder); ok {
+				c.CloseWith(s.closeCode)
+			} else {
+				s.conn.Close()
+			}
+			return
+		case <-ping.C:
+			err = s.conn.Ping()
+		case <-s.wake:
+			err = s.flush()
+		}
+		if err != nil {
+			s.conn.Close() // Dropped or too slow to take a write
+			return
+		}
+	}
+}
+
+// flush sends everything queued: the replies, then the snapshot with its
+// messages. Those are taken together so a later snapshot's messages can't
+// go out before an older snapshot.
+func (s *session) flush() error {
+	for {
+		s.mu.Lock()
+		var msgs [][]byte
+		if len(s.queue) > 0 {
+			msgs = [][]byte{s.queue[0]}
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+		} else if s.snapshot != nil {
+			msgs = append(s.batch, s.snapshot)
+			s.batch, s.snapshot = nil, nil
+		}
+		s.mu.Unlock()
+		if msgs == nil {
+			return nil
+		}
+		for _, msg := range msgs {
+			if err := s.conn.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
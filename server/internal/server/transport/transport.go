@@ -1,4 +1,21 @@
 package transport
 
+import "errors"
+
+// ErrClosed is returned once either side has closed the connection
+var ErrClosed = errors.New("transport closed")
+
+// Transport carries whole messages between the server and one client.
+// Send and Receive may be called from different goroutines, but each from
+// one goroutine at a time.
 type Transport interface {
+	// Send writes one message
+	Send(msg []byte) error
+	// Receive blocks until the next message arrives
+	Receive() ([]byte, error)
+	// Ping asks the client to show it is still there, its answer only
+	// resets the idle timeout of Receive
+	Ping() error
+	// Close ends the connection, safe to call more than once
+	Close() error
 }
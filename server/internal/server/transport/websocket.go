@@ -1,5 +1,276 @@
 package transport
 
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal WebSocket server (RFC 6455): the opening handshake, text and
+// binary messages split over any number of frames, ping, pong and close.
+// Extensions and subprotocols are not supported.
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+
+	// MaxMessageSize bounds messages from clients, which only send small
+	// control requests
+	MaxMessageSize = 1 << 20
+
+	handshakeGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// Close codes, see RFC 6455 section 7.4.1
+	CloseNormal    = 1000
+	CloseGoingAway = 1001 // The server is shutting down or gave up on the client
+	closeProtocol  = 1002
+	closeTooBig    = 1009
+)
+
+var (
+	ErrNotWebSocket  = errors.New("not a websocket handshake")
+	ErrProtocol      = errors.New("websocket protocol error")
+	ErrMessageTooBig = errors.New("websocket message too big")
+	ErrIdle          = errors.New("websocket client idle")
+)
+
+// WebSocketTransport is a server side WebSocket connection. The timeouts
+// are zero, meaning none, until set right after Upgrade.
 type WebSocketTransport struct {
-	// TODO: Will implement Transport interface
+	IdleTimeout  time.Duration // Receive fails after hearing nothing for this long, pongs included
+	WriteTimeout time.Duration // Writing one frame fails after this long
+
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu   sync.Mutex // Frames from Send, pongs and close must not interleave
+	closeOnce sync.Once
+}
+
+// Upgrade answers a WebSocket handshake and takes over the connection. On
+// failure it has already written an HTTP error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*WebSocketTransport, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, ErrNotWebSocket.Error(), http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrNotWebSocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be upgraded", http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + handshakeGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &WebSocketTransport{conn: conn, r: rw.Reader}, nil
+}
+
+// headerContains reports whether a comma separated header lists token
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Send writes msg as one text message
+func (t *WebSocketTransport) Send(msg []byte) error {
+	return t.writeFrame(opText, msg)
+}
+
+// Ping sends a ping, browsers answer it on their own
+func (t *WebSocketTransport) Ping() error {
+	return t.writeFrame(opPing, nil)
+}
+
+// Receive returns the next text or binary message, answering pings and
+// close frames on the way. It returns ErrClosed once the client closes.
+func (t *WebSocketTransport) Receive() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := t.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			if err := t.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			t.closeWith(payload)
+			return nil, ErrClosed
+		case opText, opBinary, opContinuation:
+			if (op == opContinuation) != started {
+				return nil, t.fail(ErrProtocol)
+			}
+			if len(msg)+len(payload) > MaxMessageSize {
+				return nil, t.fail(ErrMessageTooBig)
+			}
+			msg, started = append(msg, payload...), true
+			if fin {
+				return msg, nil
+			}
+		default:
+			return nil, t.fail(ErrProtocol)
+		}
+	}
+}
+
+// Close sends a normal closure and closes the connection
+func (t *WebSocketTransport) Close() error {
+	return t.CloseWith(CloseNormal)
+}
+
+// CloseWith sends a close frame with the given code and closes the
+// connection. Only the first close of a connection sends anything.
+func (t *WebSocketTransport) CloseWith(code uint16) error {
+	t.closeWith(binary.BigEndian.AppendUint16(nil, code))
+	return nil
+}
+
+// fail closes the connection after a client broke the protocol
+func (t *WebSocketTransport) fail(err error) error {
+	code := uint16(closeProtocol)
+	if errors.Is(err, ErrMessageTooBig) {
+		code = closeTooBig
+	}
+	t.closeWith(binary.BigEndian.AppendUint16(nil, code))
+	return err
+}
+
+func (t *WebSocketTransport) closeWith(payload []byte) {
+	t.closeOnce.Do(func() {
+		t.writeFrame(opClose, payload)
+		t.conn.Close()
+	})
+}
+
+// readFrame reads one frame. Clients must mask every frame they send.
+func (t *WebSocketTransport) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if t.IdleTimeout > 0 {
+		t.conn.SetReadDeadline(time.Now().Add(t.IdleTimeout))
+	}
+	var header [2]byte
+	if _, err := io.ReadFull(t.r, header[:]); err != nil {
+		return false, 0, nil, t.readErr(err)
+	}
+	fin, op = header[0]&0x80 != 0, header[0]&0x0F
+	masked := header[1]&0x80 != 0
+	if header[0]&0x70 != 0 || !masked {
+		return false, 0, nil, t.fail(ErrProtocol) // Reserved bits need an extension
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(t.r, ext[:]); err != nil {
+			return false, 0, nil, t.readErr(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(t.r, ext[:]); err != nil {
+			return false, 0, nil, t.readErr(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, t.fail(ErrProtocol) // Control frames are short and whole
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, t.fail(ErrMessageTooBig)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(t.r, mask[:]); err != nil {
+		return false, 0, nil, t.readErr(err)
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(t.r, payload); err != nil {
+		return false, 0, nil, t.readErr(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// readErr reports a dropped connection as ErrClosed and a silent one as
+// ErrIdle
+func (t *WebSocketTransport) readErr(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		t.closeWith(binary.BigEndian.AppendUint16(nil, CloseGoingAway))
+		return ErrIdle
+	}
+	t.closeOnce.Do(func() { t.conn.Close() })
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+// writeFrame writes an unmasked frame, servers never mask
+func (t *WebSocketTransport) writeFrame(op byte, payload []byte) error {
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if t.WriteTimeout > 0 {
+		t.conn.SetWriteDeadline(time.Now().Add(t.WriteTimeout))
+	}
+	if _, err := (&net.Buffers{header, payload}).WriteTo(t.conn); err != nil {
+		if errors.Is(err, net.ErrClosed) {
+			return ErrClosed
+		}
+		return err
+	}
+	return nil
 }